@@ -69,7 +69,15 @@ func (e *Exporter) header(w http.ResponseWriter, name, description string, isGau
 func (e *Exporter) row(w http.ResponseWriter, name string, group []label.Label, extra string, value interface{}) {
 	fmt.Fprint(w, name)
 	buf := &bytes.Buffer{}
-	fmt.Fprint(buf, group)
+	for _, l := range group {
+		if !l.Valid() {
+			continue
+		}
+		if buf.Len() > 0 {
+			fmt.Fprint(buf, ",")
+		}
+		fmt.Fprint(buf, l)
+	}
 	if extra != "" {
 		if buf.Len() > 0 {
 			fmt.Fprint(buf, ",")
@@ -230,7 +230,7 @@ func TestHttpFile(t *testing.T) {
 	mock.ListenAndServe("c.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(500)
 	}))
-	err500 := fmt.Errorf("http.Get %s error: status %d (%s)", "http://c.com/", 500, "")
+	err500 := fmt.Errorf("http.Get %s error: status %d (%s)", "http://c.com/", 500, "500 Internal Server Error")
 	aCom := Http("http://a.com", context.TODO()).With(mockClient, nil)
 	bCom := Http("http://b.com").With(mockClient, nil)
 	cCom := Http("http://c.com").With(mockClient, nil)
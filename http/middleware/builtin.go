@@ -0,0 +1,135 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/qiniu/x/errors"
+	"github.com/qiniu/x/http/fallback"
+	"github.com/qiniu/x/http/nocache"
+	"github.com/qiniu/x/http/tracer"
+	"github.com/qiniu/x/reqid"
+)
+
+// Default priorities of builtin middlewares.
+const (
+	PriorityRecover  = 0
+	PriorityReqid    = 100
+	PriorityTracer   = 200
+	PriorityNocache  = 300
+	PriorityFallback = 400
+)
+
+func init() {
+	Register("recover", PriorityRecover, noArgs(Recover))
+	Register("reqid", PriorityReqid, noArgs(Reqid))
+	Register("tracer", PriorityTracer, noArgs(Tracer))
+	Register("nocache", PriorityNocache, noArgs(Nocache))
+	Register("fallback", PriorityFallback, newFallback)
+}
+
+func noArgs(fn Handler) Factory {
+	return func(args json.RawMessage) (Handler, error) {
+		return fn, nil
+	}
+}
+
+// -------------------------------------------------------------------------------
+
+// Recover recovers from panics of next handlers, logs the panic detail and
+// replies 500 Internal Server Error.
+func Recover(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			err, ok := v.(error)
+			if !ok {
+				err = fmt.Errorf("%v", v)
+			}
+			log.Printf("panic: %s %v\n%s", r.Method, r.URL, errors.Detail(err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}()
+	next.ServeHTTP(w, r)
+}
+
+// Reqid injects a request id into the request context (see reqid.FromContext)
+// and the X-Reqid response header.
+func Reqid(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	ctx := reqid.NewContextWith(r.Context(), w, r)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// Tracer logs requests and their results, see tracer.New.
+func Tracer(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	tracer.New(next).ServeHTTP(w, r)
+}
+
+// Nocache removes Last-Modified from responses, see nocache.New.
+func Nocache(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	nocache.New(next).ServeHTTP(w, r)
+}
+
+// -------------------------------------------------------------------------------
+
+// FallbackArgs represents arguments of the fallback middleware.
+type FallbackArgs struct {
+	// Status lists status codes that trigger the fallback (default: 404).
+	Status []int `json:"status,omitempty"`
+
+	// Path is the URL path to serve instead (eg. /index.html).
+	Path string `json:"path"`
+}
+
+// Fallback returns a middleware that serves args.Path by next handler if
+// the status code of the original request is in args.Status.
+func Fallback(args *FallbackArgs) Handler {
+	status := args.Status
+	if len(status) == 0 {
+		status = []int{http.StatusNotFound}
+	}
+	path := args.Path
+	return func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		second := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = r.Clone(r.Context())
+			r.URL.Path, r.URL.RawPath = path, ""
+			next.ServeHTTP(w, r)
+		})
+		fallback.New(status, next, second).ServeHTTP(w, r)
+	}
+}
+
+func newFallback(raw json.RawMessage) (Handler, error) {
+	var args FallbackArgs
+	if raw != nil {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, err
+		}
+	}
+	if args.Path == "" {
+		return nil, errors.New("fallback: path not specified")
+	}
+	return Fallback(&args), nil
+}
+
+// -------------------------------------------------------------------------------
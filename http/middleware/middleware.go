@@ -0,0 +1,157 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package middleware composes named, ordered http middlewares on top of
+// plugins.Handler.
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/qiniu/x/http/plugins"
+)
+
+// Handler is the middleware function signature: it handles the request and
+// calls next to pass it down the chain.
+type Handler = plugins.Handler
+
+// -------------------------------------------------------------------------------
+
+// Middleware represents a named middleware.
+type Middleware struct {
+	// Name identifies the middleware.
+	Name string
+
+	// Priority orders middlewares: a lower priority runs earlier (outer).
+	// Middlewares with equal priorities keep their given order.
+	Priority int
+
+	// Prefixes restricts the middleware to requests whose URL path has one of
+	// these prefixes. Empty means all paths.
+	Prefixes []string
+
+	// Methods restricts the middleware to requests with one of these methods.
+	// Empty means all methods.
+	Methods []string
+
+	// Handler is the middleware function.
+	Handler Handler
+}
+
+// Match reports whether the middleware applies to request r.
+func (p *Middleware) Match(r *http.Request) bool {
+	if len(p.Methods) > 0 && !containsFold(p.Methods, r.Method) {
+		return false
+	}
+	if len(p.Prefixes) > 0 {
+		path := r.URL.Path
+		for _, prefix := range p.Prefixes {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsFold(s []string, e string) bool {
+	for _, a := range s {
+		if strings.EqualFold(a, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// -------------------------------------------------------------------------------
+
+// ShortCircuitFunc is called when middleware name handles a request without
+// calling next.
+type ShortCircuitFunc = func(name string, w http.ResponseWriter, r *http.Request)
+
+// Chain represents an ordered list of middlewares.
+type Chain struct {
+	mws []*Middleware
+
+	// OnShortCircuit, if not nil, is called when a middleware ends the chain.
+	OnShortCircuit ShortCircuitFunc
+}
+
+// NewChain creates a middleware chain ordered by Priority.
+func NewChain(mws ...*Middleware) *Chain {
+	p := &Chain{}
+	p.Use(mws...)
+	return p
+}
+
+// Use adds middlewares into the chain.
+func (p *Chain) Use(mws ...*Middleware) *Chain {
+	p.mws = append(p.mws, mws...)
+	sort.SliceStable(p.mws, func(i, j int) bool {
+		return p.mws[i].Priority < p.mws[j].Priority
+	})
+	return p
+}
+
+// Names returns names of the middlewares in running order.
+func (p *Chain) Names() []string {
+	names := make([]string, len(p.mws))
+	for i, mw := range p.mws {
+		names[i] = mw.Name
+	}
+	return names
+}
+
+// Then returns a http.Handler that runs the chain and then h.
+func (p *Chain) Then(h http.Handler) http.Handler {
+	for i := len(p.mws) - 1; i >= 0; i-- {
+		h = p.wrap(p.mws[i], h)
+	}
+	return h
+}
+
+func (p *Chain) wrap(mw *Middleware, next http.Handler) http.Handler {
+	fn, onShort := mw.Handler, p.OnShortCircuit
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !mw.Match(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if onShort == nil {
+			fn(w, r, next)
+			return
+		}
+		called := false
+		fn(w, r, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			next.ServeHTTP(w, r)
+		}))
+		if !called {
+			onShort(mw.Name, w, r)
+		}
+	})
+}
+
+// New returns a http.Handler that runs middlewares mws (ordered by Priority)
+// and then h.
+func New(h http.Handler, mws ...*Middleware) http.Handler {
+	return NewChain(mws...).Then(h)
+}
+
+// -------------------------------------------------------------------------------
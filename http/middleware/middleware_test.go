@@ -0,0 +1,147 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/x/errors"
+	"github.com/qiniu/x/http/middleware"
+	"github.com/qiniu/x/reqid"
+)
+
+// -------------------------------------------------------------------------------
+
+func tag(name string, trace *[]string) middleware.Handler {
+	return func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		*trace = append(*trace, name)
+		next.ServeHTTP(w, r)
+	}
+}
+
+func TestOrderAndMatch(t *testing.T) {
+	var trace []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "h")
+	})
+	chain := middleware.NewChain(
+		&middleware.Middleware{Name: "c", Priority: 30, Handler: tag("c", &trace)},
+		&middleware.Middleware{Name: "a", Priority: 10, Handler: tag("a", &trace)},
+		&middleware.Middleware{Name: "b", Priority: 20, Handler: tag("b", &trace), Prefixes: []string{"/api/"}},
+		&middleware.Middleware{Name: "d", Priority: 20, Handler: tag("d", &trace), Methods: []string{"post"}},
+	)
+	if names := strings.Join(chain.Names(), ","); names != "a,b,d,c" {
+		t.Fatal("Names:", names)
+	}
+	hdl := chain.Then(h)
+	cases := []struct {
+		method, path, trace string
+	}{
+		{"GET", "/api/foo", "a,b,c,h"},
+		{"GET", "/foo", "a,c,h"},
+		{"POST", "/api/foo", "a,b,d,c,h"},
+		{"POST", "/foo", "a,d,c,h"},
+	}
+	for _, c := range cases {
+		trace = trace[:0]
+		hdl.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(c.method, c.path, nil))
+		if ret := strings.Join(trace, ","); ret != c.trace {
+			t.Fatal(c.method, c.path, "trace:", ret)
+		}
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	deny := func(w http.ResponseWriter, r *http.Request, next http.Handler) {
+		w.WriteHeader(403)
+	}
+	var shorted string
+	chain := middleware.NewChain(&middleware.Middleware{Name: "deny", Handler: deny})
+	chain.OnShortCircuit = func(name string, w http.ResponseWriter, r *http.Request) {
+		shorted = name
+	}
+	hdl := chain.Then(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called")
+	}))
+	w := httptest.NewRecorder()
+	hdl.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 403 || shorted != "deny" {
+		t.Fatal("TestShortCircuit:", w.Code, shorted)
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	var confs []middleware.Config
+	err := json.Unmarshal([]byte(`[
+		{"name": "fallback", "args": {"path": "/index.html"}, "methods": ["GET"]},
+		{"name": "reqid"},
+		{"name": "nocache"},
+		{"name": "recover"}
+	]`), &confs)
+	if err != nil {
+		t.Fatal("json.Unmarshal:", err)
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := reqid.FromContext(r.Context()); !ok {
+			t.Fatal("reqid not found")
+		}
+		switch r.URL.Path {
+		case "/index.html":
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.WriteHeader(200)
+			w.Write([]byte("index"))
+		case "/panic":
+			panic("oops")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	hdl, err := middleware.NewFromConfig(h, confs)
+	if err != nil {
+		t.Fatal("NewFromConfig:", err)
+	}
+
+	w := httptest.NewRecorder()
+	hdl.ServeHTTP(w, httptest.NewRequest("GET", "/app/page", nil))
+	if w.Code != 200 || w.Body.String() != "index" {
+		t.Fatal("fallback:", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Reqid") == "" || w.Header().Get("Last-Modified") != "" {
+		t.Fatal("header:", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	hdl.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != 500 {
+		t.Fatal("recover:", w.Code)
+	}
+}
+
+func TestBuildError(t *testing.T) {
+	if _, err := middleware.Build([]middleware.Config{{Name: "unknown"}}); !errors.IsNotFound(err) {
+		t.Fatal("Build unknown:", err)
+	}
+	if _, err := middleware.Build([]middleware.Config{{Name: "fallback"}}); err == nil {
+		t.Fatal("Build fallback without path: no error")
+	}
+}
+
+// -------------------------------------------------------------------------------
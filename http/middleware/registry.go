@@ -0,0 +1,127 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/qiniu/x/errors"
+)
+
+// -------------------------------------------------------------------------------
+
+// Factory creates a middleware Handler from its configuration arguments.
+// args is nil if no arguments are specified.
+type Factory = func(args json.RawMessage) (Handler, error)
+
+// Config represents the configuration of a middleware instance.
+type Config struct {
+	Name     string          `json:"name"`
+	Priority *int            `json:"priority,omitempty"` // nil means the registered priority
+	Prefixes []string        `json:"prefixes,omitempty"`
+	Methods  []string        `json:"methods,omitempty"`
+	Args     json.RawMessage `json:"args,omitempty"`
+}
+
+type factoryEntry struct {
+	fn       Factory
+	priority int
+}
+
+// Registry represents a set of middleware factories.
+type Registry struct {
+	factories map[string]factoryEntry
+	mutex     sync.RWMutex
+}
+
+// NewRegistry creates an empty middleware registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]factoryEntry)}
+}
+
+// Register registers a middleware factory with its default priority.
+func (p *Registry) Register(name string, priority int, fn Factory) {
+	p.mutex.Lock()
+	p.factories[name] = factoryEntry{fn, priority}
+	p.mutex.Unlock()
+}
+
+// Build creates middlewares according to their configurations.
+func (p *Registry) Build(confs []Config) (mws []*Middleware, err error) {
+	mws = make([]*Middleware, 0, len(confs))
+	for _, conf := range confs {
+		p.mutex.RLock()
+		e, ok := p.factories[conf.Name]
+		p.mutex.RUnlock()
+		if !ok {
+			return nil, &errors.NotFound{Category: "middleware " + conf.Name}
+		}
+		fn, err := e.fn(conf.Args)
+		if err != nil {
+			return nil, fmt.Errorf("middleware %s: %w", conf.Name, err)
+		}
+		priority := e.priority
+		if conf.Priority != nil {
+			priority = *conf.Priority
+		}
+		mws = append(mws, &Middleware{
+			Name:     conf.Name,
+			Priority: priority,
+			Prefixes: conf.Prefixes,
+			Methods:  conf.Methods,
+			Handler:  fn,
+		})
+	}
+	return
+}
+
+// New returns a http.Handler that runs middlewares specified by confs and
+// then h.
+func (p *Registry) New(h http.Handler, confs []Config) (http.Handler, error) {
+	mws, err := p.Build(confs)
+	if err != nil {
+		return nil, err
+	}
+	return New(h, mws...), nil
+}
+
+// -------------------------------------------------------------------------------
+
+// Default is the default middleware registry. All builtin middlewares are
+// registered into it.
+var Default = NewRegistry()
+
+// Register registers a middleware factory into the Default registry.
+func Register(name string, priority int, fn Factory) {
+	Default.Register(name, priority, fn)
+}
+
+// Build creates middlewares from the Default registry.
+func Build(confs []Config) ([]*Middleware, error) {
+	return Default.Build(confs)
+}
+
+// NewFromConfig returns a http.Handler that runs middlewares specified by
+// confs (from the Default registry) and then h.
+func NewFromConfig(h http.Handler, confs []Config) (http.Handler, error) {
+	return Default.New(h, confs)
+}
+
+// -------------------------------------------------------------------------------
@@ -0,0 +1,213 @@
+/*
+ Copyright 2026 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package log
+
+import (
+	"io"
+	"sync"
+)
+
+// An AsyncPolicy specifies the behavior of async logging when the buffer
+// is full.
+type AsyncPolicy int
+
+const (
+	// AsyncBlock blocks logging until there is room in the buffer.
+	AsyncBlock AsyncPolicy = iota
+	// AsyncDropLow drops debug and info entries, and blocks for others.
+	AsyncDropLow
+	// AsyncSample keeps one of every SampleRate debug and info entries,
+	// drops the others, and blocks for entries of other levels.
+	AsyncSample
+)
+
+// AsyncOptions are options of async logging.
+type AsyncOptions struct {
+	// BufferSize is the number of log entries the buffer holds. If zero,
+	// DefaultAsyncBufferSize is used.
+	BufferSize int
+
+	// Policy specifies the behavior when the buffer is full.
+	Policy AsyncPolicy
+
+	// SampleRate is the sample rate of AsyncSample. If zero, 10 is used.
+	SampleRate int
+}
+
+// DefaultAsyncBufferSize is the default BufferSize of AsyncOptions.
+const DefaultAsyncBufferSize = 1024
+
+// StatDropped is the index of the numbers of log entries dropped by async
+// logging in the result of Logger.Stat: stats[StatDropped+lvl] is the
+// number of dropped entries of level lvl.
+const StatDropped = 6
+
+type asyncEntry struct {
+	b    []byte
+	done chan error // not nil for a flush request
+}
+
+// asyncWriter writes log entries in background.
+type asyncWriter struct {
+	opts  AsyncOptions
+	ch    chan asyncEntry
+	nfull int // number of debug and info entries when the buffer is full, for sampling
+
+	mu   sync.Mutex // protects out and err
+	out  io.Writer
+	err  error // first write error since the last flush
+	exit chan struct{}
+}
+
+func newAsyncWriter(out io.Writer, opts AsyncOptions) *asyncWriter {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultAsyncBufferSize
+	}
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 10
+	}
+	a := &asyncWriter{
+		opts: opts,
+		ch:   make(chan asyncEntry, opts.BufferSize),
+		out:  out,
+		exit: make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.exit)
+	for e := range a.ch {
+		a.mu.Lock()
+		out := a.out
+		if e.done != nil {
+			err := a.err
+			a.err = nil
+			a.mu.Unlock()
+			if s, ok := out.(interface{ Sync() error }); ok && err == nil {
+				err = s.Sync()
+			}
+			e.done <- err
+			continue
+		}
+		a.mu.Unlock()
+		if _, err := out.Write(e.b); err != nil {
+			a.mu.Lock()
+			if a.err == nil {
+				a.err = err
+			}
+			a.mu.Unlock()
+		}
+	}
+}
+
+func (a *asyncWriter) setOutput(out io.Writer) {
+	a.mu.Lock()
+	a.out = out
+	a.mu.Unlock()
+}
+
+// enqueue queues a log entry b of level lvl, and reports whether it's
+// not dropped. It's called with the lock of the logger held.
+func (a *asyncWriter) enqueue(lvl int, b []byte) bool {
+	select {
+	case a.ch <- asyncEntry{b: b}:
+		return true
+	default:
+	}
+	if a.drop(lvl) {
+		return false
+	}
+	a.ch <- asyncEntry{b: b}
+	return true
+}
+
+// drop reports whether to drop a log entry of level lvl when the buffer is
+// full, according to the policy.
+func (a *asyncWriter) drop(lvl int) bool {
+	if lvl > Linfo {
+		return false
+	}
+	switch a.opts.Policy {
+	case AsyncDropLow:
+		return true
+	case AsyncSample:
+		a.nfull++
+		return (a.nfull-1)%a.opts.SampleRate != 0
+	}
+	return false
+}
+
+// -----------------------------------------
+
+// SetAsync makes the logger write log entries to its output in background
+// through a bounded buffer, so that logging isn't blocked by a slow
+// writer. Call Flush or Close to wait for buffered entries to be written.
+// It's a no-op if the logger is already async.
+func (l *Logger) SetAsync(opts AsyncOptions) {
+	root := l.rootLogger()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	if root.async == nil {
+		root.async = newAsyncWriter(root.out, opts)
+	}
+}
+
+// Flush waits for log entries buffered by async logging to be written, and
+// syncs the output if it has a Sync method (like *os.File). It returns the
+// first error of writing since the last flush.
+func (l *Logger) Flush() error {
+	root := l.rootLogger()
+	root.mu.Lock()
+	a := root.async
+	if a == nil {
+		root.mu.Unlock()
+		return nil
+	}
+	done := make(chan error, 1)
+	a.ch <- asyncEntry{done: done}
+	root.mu.Unlock()
+	return <-done
+}
+
+// Close flushes log entries buffered by async logging, and stops async
+// logging. The output isn't closed.
+func (l *Logger) Close() error {
+	err := l.Flush()
+	root := l.rootLogger()
+	root.mu.Lock()
+	a := root.async
+	root.async = nil
+	root.mu.Unlock()
+	if a != nil {
+		close(a.ch)
+		<-a.exit
+	}
+	return err
+}
+
+// SetAsync makes the standard logger async.
+func SetAsync(opts AsyncOptions) {
+	Std.SetAsync(opts)
+}
+
+// Flush waits for log entries buffered by the standard logger to be
+// written.
+func Flush() error {
+	return Std.Flush()
+}
@@ -0,0 +1,121 @@
+/*
+ Copyright 2026 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// gateWriter blocks writes until it's opened.
+type gateWriter struct {
+	gate    chan struct{}
+	started chan struct{} // closed when the first write starts
+	once    sync.Once
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{gate: make(chan struct{}), started: make(chan struct{})}
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func (w *gateWriter) lines() []string {
+	return strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+}
+
+func TestAsync(t *testing.T) {
+	w := newGateWriter()
+	logx := New(w, "", Llevel)
+	logx.SetAsync(AsyncOptions{BufferSize: 1, Policy: AsyncDropLow})
+	logx.Info("0")
+	<-w.started // "0" is being written, and "1" will be buffered
+	for i := 1; i < 20; i++ {
+		logx.Infof("%d", i)
+	}
+	done := make(chan struct{})
+	go func() {
+		logx.Warn("full") // blocks
+		close(done)
+	}()
+	close(w.gate)
+	<-done
+	if err := logx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := w.lines(); strings.Join(lines, ",") != "[INFO] 0,[INFO] 1,[WARN] full" {
+		t.Fatalf("lines: %q", lines)
+	}
+	stats := logx.Stat()
+	if stats[Linfo] != 20 || stats[StatDropped+Linfo] != 18 || stats[StatDropped+Lwarn] != 0 {
+		t.Fatal("stats:", stats)
+	}
+	logx.Info("sync") // back to sync mode after Close
+	if !strings.HasSuffix(w.String(), "[INFO] sync\n") {
+		t.Fatal("after Close:", w.String())
+	}
+}
+
+func TestAsyncBlock(t *testing.T) {
+	w := newGateWriter()
+	logx := New(w, "", Llevel)
+	logx.SetAsync(AsyncOptions{BufferSize: 2})
+	with := logx.With("k", 1)
+	go func() {
+		<-w.started
+		close(w.gate)
+	}()
+	for i := 0; i < 100; i++ {
+		with.Infow("hello", "i", i)
+	}
+	if err := with.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := w.lines(); len(lines) != 100 || lines[99] != "[INFO] hello k=1 i=99" {
+		t.Fatal("lines:", len(lines), lines[len(lines)-1])
+	}
+	logx.Close()
+}
+
+func TestAsyncSample(t *testing.T) {
+	a := &asyncWriter{opts: AsyncOptions{Policy: AsyncSample, SampleRate: 10}}
+	kept := 0
+	for i := 0; i < 20; i++ {
+		if !a.drop(Ldebug) {
+			kept++
+		}
+	}
+	if kept != 2 || a.drop(Lerror) {
+		t.Fatal("kept:", kept)
+	}
+}
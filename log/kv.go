@@ -0,0 +1,316 @@
+/*
+ Copyright 2026 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// A Record is a log entry to be encoded.
+type Record struct {
+	Time   time.Time
+	Level  int    // Ldebug, Linfo, etc.
+	Prefix string // prefix of the logger
+	Flag   int    // flags of the logger
+	File   string // empty if not required by Flag
+	Line   int
+	ReqID  string
+	Msg    string
+	KVs    []interface{} // key-value pairs
+}
+
+// An Encoder encodes log records of a Logger.
+type Encoder interface {
+	Encode(buf *bytes.Buffer, r *Record)
+}
+
+var (
+	// TextEncoder encodes a log record as a line of header (see Ldate,
+	// Llevel, etc.), message, and key=value pairs separated by spaces. It's
+	// the default encoder of a Logger.
+	TextEncoder Encoder = textEncoder{}
+
+	// JSONEncoder encodes a log record as a line of JSON object. Its time,
+	// level and caller are present according to the flags of the logger.
+	JSONEncoder Encoder = jsonEncoder{}
+)
+
+// badKey is the key of a value without a key in key-value pairs.
+const badKey = "!BADKEY"
+
+// eachKV calls fn for each key-value pair of kvs, which is a list of
+// alternating keys and values, or slog.Attr values.
+func eachKV(kvs []interface{}, fn func(key string, val interface{})) {
+	for i := 0; i < len(kvs); i++ {
+		switch k := kvs[i].(type) {
+		case string:
+			if i+1 < len(kvs) {
+				fn(k, kvs[i+1])
+				i++
+			} else {
+				fn(badKey, k)
+			}
+		case slog.Attr:
+			eachAttr("", k, fn)
+		default:
+			fn(badKey, k)
+		}
+	}
+}
+
+// eachAttr calls fn for an attr, or each attr of a group with keys
+// qualified by group names.
+func eachAttr(group string, a slog.Attr, fn func(key string, val interface{})) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		if !a.Equal(slog.Attr{}) {
+			fn(group+a.Key, a.Value.Any())
+		}
+		return
+	}
+	if a.Key != "" {
+		group += a.Key + "."
+	}
+	for _, ga := range a.Value.Group() {
+		eachAttr(group, ga, fn)
+	}
+}
+
+// -----------------------------------------
+
+type textEncoder struct{}
+
+func (textEncoder) Encode(buf *bytes.Buffer, r *Record) {
+	formatHeader(buf, r.Prefix, r.Flag, r.Time, r.File, r.Line, r.Level, r.ReqID)
+	msg := r.Msg
+	if len(r.KVs) == 0 {
+		buf.WriteString(msg)
+		if len(msg) > 0 && msg[len(msg)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		return
+	}
+	msg = strings.TrimSuffix(msg, "\n")
+	buf.WriteString(msg)
+	sep := msg != ""
+	eachKV(r.KVs, func(key string, val interface{}) {
+		if sep {
+			buf.WriteByte(' ')
+		}
+		sep = true
+		writeText(buf, key)
+		buf.WriteByte('=')
+		writeText(buf, textOf(val))
+	})
+	buf.WriteByte('\n')
+}
+
+func textOf(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(val)
+}
+
+// writeText writes s, which is quoted if it's empty or contains spaces,
+// quotes, '=' or non-printable characters.
+func writeText(buf *bytes.Buffer, s string) {
+	if needsQuote(s) {
+		buf.WriteString(strconv.Quote(s))
+	} else {
+		buf.WriteString(s)
+	}
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, c := range s {
+		if c <= ' ' || c == '=' || c == '"' || c == '\\' || c == 0x7f || c == utf8.RuneError {
+			return true
+		}
+	}
+	return false
+}
+
+// -----------------------------------------
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(buf *bytes.Buffer, r *Record) {
+	buf.WriteByte('{')
+	sep := false
+	field := func(key string, val interface{}) {
+		if sep {
+			buf.WriteByte(',')
+		}
+		sep = true
+		writeJSON(buf, key)
+		buf.WriteByte(':')
+		writeJSON(buf, val)
+	}
+	if r.Flag&(Ldate|Ltime|Lmicroseconds) != 0 {
+		layout := time.RFC3339
+		if r.Flag&Lmicroseconds != 0 {
+			layout = "2006-01-02T15:04:05.000000Z07:00"
+		}
+		field("time", r.Time.Format(layout))
+	}
+	if r.Flag&Llevel != 0 {
+		field("level", strings.Trim(levels[r.Level], "[]"))
+	}
+	if r.Prefix != "" {
+		field("prefix", r.Prefix)
+	}
+	if r.ReqID != "" {
+		field("reqid", r.ReqID)
+	}
+	if r.Flag&(Lshortfile|Llongfile|Lintermediatefile) != 0 {
+		field("caller", fileOf(r.Flag, r.File)+":"+strconv.Itoa(r.Line))
+	}
+	field("msg", strings.TrimSuffix(r.Msg, "\n"))
+	eachKV(r.KVs, field)
+	buf.WriteString("}\n")
+}
+
+func writeJSON(buf *bytes.Buffer, val interface{}) {
+	switch v := val.(type) {
+	case error:
+		val = v.Error()
+	case time.Duration:
+		val = v.String()
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(val))
+	}
+	buf.Write(b)
+}
+
+// -----------------------------------------
+
+// SetEncoder sets the encoder of log entries, which is TextEncoder by
+// default.
+func (l *Logger) SetEncoder(enc Encoder) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc = enc
+}
+
+// With returns a child logger which adds key-value pairs kv to each log
+// entry. kv is a list of alternating keys and values, or slog.Attr values.
+// The child logger writes to the same output as l.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kvs := make([]interface{}, 0, len(l.kvs)+len(kv))
+	kvs = append(append(kvs, l.kvs...), kv...)
+	return &Logger{
+		prefix: l.prefix, flag: l.flag, Level: l.Level,
+		enc: l.enc, handler: l.handler, kvs: kvs, root: l.rootLogger(),
+	}
+}
+
+// Debugw prints a debug message with key-value pairs.
+func (l *Logger) Debugw(msg string, kv ...interface{}) {
+	if Ldebug < l.Level {
+		return
+	}
+	l.output("", Ldebug, 2, msg, kv)
+}
+
+// Infow prints a prompting message with key-value pairs.
+func (l *Logger) Infow(msg string, kv ...interface{}) {
+	if Linfo < l.Level {
+		return
+	}
+	l.output("", Linfo, 2, msg, kv)
+}
+
+// Warnw prints a warning message with key-value pairs.
+func (l *Logger) Warnw(msg string, kv ...interface{}) {
+	if Lwarn < l.Level {
+		return
+	}
+	l.output("", Lwarn, 2, msg, kv)
+}
+
+// Errorw prints an error message with key-value pairs.
+func (l *Logger) Errorw(msg string, kv ...interface{}) {
+	if Lerror < l.Level {
+		return
+	}
+	l.output("", Lerror, 2, msg, kv)
+}
+
+// -----------------------------------------
+
+// SetEncoder sets the encoder of the standard logger.
+func SetEncoder(enc Encoder) {
+	Std.SetEncoder(enc)
+}
+
+// With returns a child logger of the standard logger which adds key-value
+// pairs kv to each log entry.
+func With(kv ...interface{}) *Logger {
+	return Std.With(kv...)
+}
+
+// Debugw prints a debug message with key-value pairs.
+func Debugw(msg string, kv ...interface{}) {
+	if Ldebug < Std.Level {
+		return
+	}
+	Std.output("", Ldebug, 2, msg, kv)
+}
+
+// Infow prints a prompting message with key-value pairs.
+func Infow(msg string, kv ...interface{}) {
+	if Linfo < Std.Level {
+		return
+	}
+	Std.output("", Linfo, 2, msg, kv)
+}
+
+// Warnw prints a warning message with key-value pairs.
+func Warnw(msg string, kv ...interface{}) {
+	if Lwarn < Std.Level {
+		return
+	}
+	Std.output("", Lwarn, 2, msg, kv)
+}
+
+// Errorw prints an error message with key-value pairs.
+func Errorw(msg string, kv ...interface{}) {
+	if Lerror < Std.Level {
+		return
+	}
+	Std.output("", Lerror, 2, msg, kv)
+}
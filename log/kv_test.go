@@ -0,0 +1,91 @@
+/*
+ Copyright 2026 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestKV(t *testing.T) {
+	var out bytes.Buffer
+	logx := New(&out, "", Llevel)
+	logx.Infow("hello", "name", "a b", "n", 1, "err", errors.New("failed"), "odd")
+	logx.With("req", 7).Warnw("", slog.Group("g", slog.Int("x", 1)))
+	logx.Info("plain")
+	expected := `[INFO] hello name="a b" n=1 err=failed !BADKEY=odd
+[WARN] req=7 g.x=1
+[INFO] plain
+`
+	if out.String() != expected {
+		t.Fatalf("text:\n%s", out.String())
+	}
+	if stats := logx.Stat(); stats[Linfo] != 2 || stats[Lwarn] != 1 {
+		t.Fatal("Stat:", stats)
+	}
+
+	out.Reset()
+	logx.SetEncoder(JSONEncoder)
+	logx.With("req", 7).Errorw("hello", "ok", true)
+	var v map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &v); err != nil {
+		t.Fatal(err, out.String())
+	}
+	if v["level"] != "ERROR" || v["msg"] != "hello" || v["req"] != 7.0 || v["ok"] != true || len(v) != 4 {
+		t.Fatal("json:", out.String())
+	}
+
+	out.Reset()
+	logx.SetFlags(Llevel | Lshortfile)
+	logx.Infof("%d", 1)
+	if !strings.HasPrefix(out.String(), `{"level":"INFO","caller":"kv_test.go:`) {
+		t.Fatal("json caller:", out.String())
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var out bytes.Buffer
+	logx := New(&out, "", Llevel|Lshortfile)
+	logger := slog.New(logx.Handler()).With("req", 7).WithGroup("g")
+	logger.Debug("hidden")
+	logger.Info("hello", "x", 1)
+	if !strings.HasPrefix(out.String(), "[INFO] kv_test.go:") || !strings.HasSuffix(out.String(), ": hello req=7 g.x=1\n") {
+		t.Fatal("Handler:", out.String())
+	}
+
+	out.Reset()
+	h := slog.NewTextHandler(&out, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	logx = NewWithHandler(h)
+	logx.With("req", 7).Warnw("hello", "x", 1)
+	logx.Debug("hidden")
+	logx.Info("world")
+	expected := "level=WARN msg=hello req=7 x=1\nlevel=INFO msg=world\n"
+	if out.String() != expected {
+		t.Fatal("NewWithHandler:", out.String())
+	}
+}
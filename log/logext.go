@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -95,6 +96,12 @@ type Logger struct {
 	out        io.Writer    // destination for output
 	buf        bytes.Buffer // for accumulating text to write
 	levelStats [6]int64
+	dropped    [6]int64      // log entries dropped by async
+	async      *asyncWriter  // see SetAsync
+	enc        Encoder       // encodes log entries; TextEncoder if nil
+	handler    slog.Handler  // if not nil, log entries are sent to it instead of out
+	kvs        []interface{} // key-value pairs added by With
+	root       *Logger       // the logger owning out, buf and levelStats of a logger created by With
 }
 
 // New creates a new Logger.
@@ -159,11 +166,20 @@ func formatFile(file string, lastN int) string {
 	return file[lastNthSlash+1:]
 }
 
-func (l *Logger) formatHeader(buf *bytes.Buffer, t time.Time, file string, line int, lvl int, reqID string) {
-	if l.prefix != "" {
-		buf.WriteString(l.prefix)
+// fileOf formats the file name of a log entry according to flag.
+func fileOf(flag int, file string) string {
+	if flag&Lintermediatefile != 0 {
+		return formatFile(file, 2)
+	} else if flag&Lshortfile != 0 {
+		return formatFile(file, 1)
+	}
+	return file
+}
+
+func formatHeader(buf *bytes.Buffer, prefix string, flag int, t time.Time, file string, line int, lvl int, reqID string) {
+	if prefix != "" {
+		buf.WriteString(prefix)
 	}
-	flag := l.flag
 	if flag&(Ldate|Ltime|Lmicroseconds) != 0 {
 		if flag&Ldate != 0 {
 			year, month, day := t.Date()
@@ -197,13 +213,8 @@ func (l *Logger) formatHeader(buf *bytes.Buffer, t time.Time, file string, line
 		buf.WriteString(levels[lvl])
 	}
 	if flag&(Lshortfile|Llongfile|Lintermediatefile) != 0 {
-		if l.flag&Lintermediatefile != 0 {
-			file = formatFile(file, 2)
-		} else if l.flag&Lshortfile != 0 {
-			file = formatFile(file, 1)
-		}
 		buf.WriteByte(' ')
-		buf.WriteString(file)
+		buf.WriteString(fileOf(flag, file))
 		buf.WriteByte(':')
 		itoa(buf, line, -1)
 		buf.WriteString(": ")
@@ -222,30 +233,71 @@ func (l *Logger) Output(reqID string, lvl int, calldepth int, s string) error {
 	if lvl < l.Level {
 		return nil
 	}
-	now := time.Now() // get this early.
-	var file string
-	var line int
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	if l.flag&(Lshortfile|Llongfile|Lintermediatefile) != 0 {
-		// release lock while getting caller info - it's expensive.
-		l.mu.Unlock()
-		var ok bool
-		_, file, line, ok = runtime.Caller(calldepth)
-		if !ok {
-			file = "???"
-			line = 0
+	return l.output(reqID, lvl, calldepth+1, s, nil)
+}
+
+// output writes a log entry of message msg and key-value pairs kvs.
+// Calldepth is counted from output, like Output.
+func (l *Logger) output(reqID string, lvl int, calldepth int, msg string, kvs []interface{}) error {
+	r := l.newRecord(time.Now(), lvl, reqID, msg, kvs) // get time early.
+	var pc uintptr
+	if l.handler != nil || r.Flag&(Lshortfile|Llongfile|Lintermediatefile) != 0 {
+		var pcs [1]uintptr
+		if runtime.Callers(calldepth+1, pcs[:]) > 0 {
+			pc = pcs[0]
+			frame, _ := runtime.CallersFrames(pcs[:]).Next()
+			r.File, r.Line = frame.File, frame.Line
+		} else {
+			r.File = "???"
 		}
-		l.mu.Lock()
 	}
-	l.levelStats[lvl]++
-	l.buf.Reset()
-	l.formatHeader(&l.buf, now, file, line, lvl, reqID)
-	l.buf.WriteString(s)
-	if len(s) > 0 && s[len(s)-1] != '\n' {
-		l.buf.WriteByte('\n')
+	return l.write(r, pc)
+}
+
+func (l *Logger) newRecord(t time.Time, lvl int, reqID, msg string, kvs []interface{}) *Record {
+	r := &Record{Time: t, Level: lvl, ReqID: reqID, Msg: msg, KVs: kvs}
+	l.mu.Lock()
+	r.Prefix, r.Flag = l.prefix, l.flag
+	if len(l.kvs) > 0 {
+		r.KVs = append(l.kvs[:len(l.kvs):len(l.kvs)], kvs...)
+	}
+	l.mu.Unlock()
+	return r
+}
+
+func (l *Logger) rootLogger() *Logger {
+	if l.root != nil {
+		return l.root
+	}
+	return l
+}
+
+// write writes a log record; pc is the program counter of its caller, or
+// zero if unknown.
+func (l *Logger) write(r *Record, pc uintptr) error {
+	l.mu.Lock()
+	enc, h := l.enc, l.handler
+	l.mu.Unlock()
+	root := l.rootLogger()
+	root.mu.Lock()
+	root.levelStats[r.Level]++
+	if h != nil {
+		root.mu.Unlock()
+		return handle(h, r, pc)
+	}
+	defer root.mu.Unlock()
+	if enc == nil {
+		enc = TextEncoder
 	}
-	_, err := l.out.Write(l.buf.Bytes())
+	root.buf.Reset()
+	enc.Encode(&root.buf, r)
+	if a := root.async; a != nil {
+		if !a.enqueue(r.Level, append([]byte(nil), root.buf.Bytes()...)) {
+			root.dropped[r.Level]++
+		}
+		return nil
+	}
+	_, err := root.out.Write(root.buf.Bytes())
 	return err
 }
 
@@ -326,18 +378,21 @@ func (l *Logger) Error(v ...interface{}) { l.Output("", Lerror, 2, fmt.Sprintln(
 // Fatal prints an error information and exit app.
 func (l *Logger) Fatal(v ...interface{}) {
 	l.Output("", Lfatal, 2, fmt.Sprint(v...))
+	l.Flush()
 	os.Exit(1)
 }
 
 // Fatalf is equivalent to l.Printf() followed by a call to os.Exit(1).
 func (l *Logger) Fatalf(format string, v ...interface{}) {
 	l.Output("", Lfatal, 2, fmt.Sprintf(format, v...))
+	l.Flush()
 	os.Exit(1)
 }
 
 // Fatalln is equivalent to l.Println() followed by a call to os.Exit(1).
 func (l *Logger) Fatalln(v ...interface{}) {
 	l.Output("", Lfatal, 2, fmt.Sprintln(v...))
+	l.Flush()
 	os.Exit(1)
 }
 
@@ -390,12 +445,14 @@ func (l *Logger) SingleStack(v ...interface{}) {
 
 // -----------------------------------------
 
-// Stat func.
+// Stat returns the numbers of log entries of each level, indexed by Ldebug,
+// Linfo, etc., followed by the numbers of entries dropped by async logging
+// of each level, indexed by StatDropped+Ldebug, etc.
 func (l *Logger) Stat() (stats []int64) {
-	l.mu.Lock()
-	v := l.levelStats
-	l.mu.Unlock()
-	return v[:]
+	root := l.rootLogger()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	return append(root.levelStats[:], root.dropped[:]...)
 }
 
 // Flags returns the output flags for the logger.
@@ -438,6 +495,9 @@ func SetOutput(w io.Writer) {
 	Std.mu.Lock()
 	defer Std.mu.Unlock()
 	Std.out = w
+	if Std.async != nil {
+		Std.async.setOutput(w)
+	}
 }
 
 // Flags returns the output flags for the standard logger.
@@ -556,18 +616,21 @@ func Error(v ...interface{}) { Std.Output("", Lerror, 2, fmt.Sprintln(v...)) }
 // Fatal is equivalent to Print() followed by a call to os.Exit(1).
 func Fatal(v ...interface{}) {
 	Std.Output("", Lfatal, 2, fmt.Sprint(v...))
+	Std.Flush()
 	os.Exit(1)
 }
 
 // Fatalf is equivalent to Printf() followed by a call to os.Exit(1).
 func Fatalf(format string, v ...interface{}) {
 	Std.Output("", Lfatal, 2, fmt.Sprintf(format, v...))
+	Std.Flush()
 	os.Exit(1)
 }
 
 // Fatalln is equivalent to Println() followed by a call to os.Exit(1).
 func Fatalln(v ...interface{}) {
 	Std.Output("", Lfatal, 2, fmt.Sprintln(v...))
+	Std.Flush()
 	os.Exit(1)
 }
 
@@ -0,0 +1,330 @@
+/*
+ Copyright 2026 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A RotatePeriod specifies how often a RotateWriter rotates the log file
+// regardless of its size.
+type RotatePeriod int
+
+const (
+	// RotateNever disables rotation by time.
+	RotateNever RotatePeriod = iota
+	// RotateHourly rotates the log file at the beginning of each hour.
+	RotateHourly
+	// RotateDaily rotates the log file at midnight.
+	RotateDaily
+)
+
+// RotateOptions are options of a RotateWriter.
+type RotateOptions struct {
+	// MaxSize is the maximum size of the log file in bytes before it's
+	// rotated. Zero means no limit.
+	MaxSize int64
+
+	// Period specifies rotation by time.
+	Period RotatePeriod
+
+	// MaxBackups is the maximum number of rotated files to keep. Zero
+	// means all are kept.
+	MaxBackups int
+
+	// Compress makes rotated files compressed by gzip.
+	Compress bool
+
+	// Symlink, if not empty, is the path of a symbolic link to the log
+	// file, which is created when the log file is opened. An existing file
+	// at the path is replaced only if it's a symbolic link.
+	Symlink string
+}
+
+var timeNow = time.Now
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// A RotateWriter is an io.WriteCloser writing to a log file, which is
+// rotated by size and/or time. A rotated file is renamed to
+// name-<time>.ext in the same directory, where name.ext is the name of the
+// log file. It's safe for concurrent use, e.g.:
+//
+//	w, err := log.NewRotateWriter("/var/log/app.log", log.RotateOptions{
+//		MaxSize: 100 << 20, Period: log.RotateDaily, MaxBackups: 7, Compress: true,
+//	})
+//	...
+//	log.SetOutput(w)
+type RotateWriter struct {
+	filename string
+	opts     RotateOptions
+
+	mu   sync.Mutex // protects the following fields
+	f    *os.File
+	size int64
+	next time.Time // next rotation by time; zero if never
+
+	mill sync.Mutex     // serializes compression and removal of backups
+	wg   sync.WaitGroup // of milling goroutines
+}
+
+// NewRotateWriter opens (or creates) a log file for appending, and returns
+// a RotateWriter of it.
+func NewRotateWriter(filename string, opts RotateOptions) (*RotateWriter, error) {
+	w := &RotateWriter{filename: filename, opts: opts}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size = f, fi.Size()
+	w.next = nextRotation(timeNow(), w.opts.Period)
+	if link := w.opts.Symlink; link != "" {
+		if target, err := filepath.Abs(w.filename); err == nil {
+			if fi, err := os.Lstat(link); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+				os.Remove(link)
+			}
+			os.Symlink(target, link) // it's not fatal if symbolic links aren't supported
+		}
+	}
+	return nil
+}
+
+// nextRotation returns the beginning of the next period after t.
+func nextRotation(t time.Time, period RotatePeriod) time.Time {
+	switch period {
+	case RotateHourly:
+		y, m, d := t.Date()
+		return time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+	case RotateDaily:
+		y, m, d := t.Date()
+		return time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// Write writes p to the log file, which is rotated first if writing p
+// would exceed MaxSize, or the rotation period has passed.
+func (w *RotateWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		if err = w.open(); err != nil {
+			return
+		}
+	}
+	if (w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize) ||
+		(!w.next.IsZero() && !timeNow().Before(w.next)) {
+		if err = w.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = w.f.Write(p)
+	w.size += int64(n)
+	return
+}
+
+// Rotate rotates the log file.
+func (w *RotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+func (w *RotateWriter) rotate() error {
+	if w.f != nil {
+		if err := w.f.Close(); err != nil {
+			return err
+		}
+		w.f = nil
+	}
+	backup := w.uniqueBackupName(timeNow())
+	if err := os.Rename(w.filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.opts.Compress || w.opts.MaxBackups > 0 {
+		w.wg.Add(1)
+		go w.millBackups(backup)
+	}
+	return nil
+}
+
+func (w *RotateWriter) split() (prefix, ext string) {
+	ext = filepath.Ext(w.filename)
+	return strings.TrimSuffix(w.filename, ext) + "-", ext
+}
+
+func (w *RotateWriter) backupName(t time.Time) string {
+	prefix, ext := w.split()
+	return prefix + t.Format(backupTimeFormat) + ext
+}
+
+// uniqueBackupName returns the name of a backup rotated at t, which isn't
+// taken by another backup, compressed or not. On a collision, t is moved
+// forward by a millisecond, so that backups are still ordered by name.
+func (w *RotateWriter) uniqueBackupName(t time.Time) string {
+	for {
+		name := w.backupName(t)
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return !os.IsNotExist(err)
+}
+
+// millBackups compresses the rotated file, and removes backups beyond
+// MaxBackups.
+func (w *RotateWriter) millBackups(backup string) {
+	defer w.wg.Done()
+	w.mill.Lock()
+	defer w.mill.Unlock()
+	if w.opts.Compress {
+		if err := compressFile(backup); err == nil {
+			os.Remove(backup)
+		}
+	}
+	if w.opts.MaxBackups > 0 {
+		backups := w.backups()
+		for i := 0; i < len(backups)-w.opts.MaxBackups; i++ {
+			os.Remove(backups[i])
+		}
+	}
+}
+
+// backups returns the rotated files, oldest first.
+func (w *RotateWriter) backups() []string {
+	prefix, ext := w.split()
+	files, _ := filepath.Glob(prefix + "*")
+	backups := files[:0]
+	for _, file := range files {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(file, ".gz"), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp[len(prefix):]); err == nil {
+			backups = append(backups, file)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i], ".gz") < strings.TrimSuffix(backups[j], ".gz")
+	})
+	return backups
+}
+
+func compressFile(name string) (err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if err1 := dst.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+	}
+	return
+}
+
+// Reopen closes and reopens the log file, which may be moved away by an
+// external tool like logrotate.
+func (w *RotateWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f != nil {
+		w.f.Close()
+		w.f = nil
+	}
+	return w.open()
+}
+
+// ReopenOnSignal reopens the log file each time the process receives one
+// of sigs, or SIGHUP if sigs is empty. It returns a function to stop it.
+func (w *RotateWriter) ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				w.Reopen()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Close closes the log file, after rotated files are compressed and
+// removed as needed.
+func (w *RotateWriter) Close() (err error) {
+	w.mu.Lock()
+	if w.f != nil {
+		err = w.f.Close()
+		w.f = nil
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+	return
+}
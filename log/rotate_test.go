@@ -0,0 +1,158 @@
+/*
+ Copyright 2026 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotateSize(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.Local)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	w, err := NewRotateWriter(name, RotateOptions{MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"line1\n", "line2\n", "line3\n", "line4\n"} {
+		now = now.Add(time.Second)
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := readFile(t, name); s != "line4\n" {
+		t.Fatal("current:", s)
+	}
+	backups := w.backups()
+	if len(backups) != 2 || !strings.HasSuffix(backups[1], "app-2026-10-19T08-00-04.000.log.gz") {
+		t.Fatal("backups:", backups)
+	}
+	f, err := os.Open(backups[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "line3\n" {
+		t.Fatal("backup:", string(b))
+	}
+}
+
+func TestRotateCollision(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.Local)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	// a regular file at the symlink path is kept
+	link := filepath.Join(dir, "current.log")
+	if err := os.WriteFile(link, []byte("not a link"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewRotateWriter(name, RotateOptions{MaxSize: 6, Symlink: link})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"line1\n", "line2\n", "line3\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups := w.backups()
+	if len(backups) != 2 {
+		t.Fatal("backups:", backups)
+	}
+	for i, want := range []string{"line1\n", "line2\n"} {
+		if s := readFile(t, backups[i]); s != want {
+			t.Fatal("backup:", backups[i], s)
+		}
+	}
+	if s := readFile(t, link); s != "not a link" {
+		t.Fatal("symlink path overwritten:", s)
+	}
+}
+
+func TestRotateTime(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	now := time.Date(2026, 10, 19, 23, 59, 0, 0, time.Local)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	link := filepath.Join(dir, "current.log")
+	w, err := NewRotateWriter(name, RotateOptions{Period: RotateDaily, Symlink: link})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	logx := New(w, "", Llevel)
+	logx.Info("day1")
+	now = now.Add(time.Minute)
+	logx.Info("day2")
+	if s := readFile(t, name); s != "[INFO] day2\n" {
+		t.Fatal("current:", s)
+	}
+	if s := readFile(t, w.backupName(now)); s != "[INFO] day1\n" {
+		t.Fatal("backup:", s)
+	}
+	if runtime.GOOS != "windows" {
+		if s := readFile(t, link); s != "[INFO] day2\n" {
+			t.Fatal("symlink:", s)
+		}
+	}
+
+	// reopen after moved away by an external tool
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	logx.Info("reopened")
+	if s := readFile(t, name); s != "[INFO] reopened\n" {
+		t.Fatal("reopened:", s)
+	}
+}
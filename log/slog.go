@@ -0,0 +1,131 @@
+/*
+ Copyright 2026 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// levelOf converts a slog level to a log level.
+func levelOf(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return Ldebug
+	case level < slog.LevelWarn:
+		return Linfo
+	case level < slog.LevelError:
+		return Lwarn
+	}
+	return Lerror
+}
+
+var slogLevels = [...]slog.Level{
+	Ldebug: slog.LevelDebug,
+	Linfo:  slog.LevelInfo,
+	Lwarn:  slog.LevelWarn,
+	Lerror: slog.LevelError,
+	Lpanic: slog.LevelError + 4,
+	Lfatal: slog.LevelError + 8,
+}
+
+// handle sends a log record to a slog handler.
+func handle(h slog.Handler, r *Record, pc uintptr) error {
+	ctx := context.Background()
+	level := slogLevels[r.Level]
+	if !h.Enabled(ctx, level) {
+		return nil
+	}
+	sr := slog.NewRecord(r.Time, level, strings.TrimSuffix(r.Msg, "\n"), pc)
+	if r.ReqID != "" {
+		sr.AddAttrs(slog.String("reqid", r.ReqID))
+	}
+	eachKV(r.KVs, func(key string, val interface{}) {
+		sr.AddAttrs(slog.Any(key, val))
+	})
+	return h.Handle(ctx, sr)
+}
+
+// NewWithHandler creates a Logger which sends log entries to a slog
+// handler, instead of writing them to an io.Writer. The prefix and flags
+// of the Logger are not used.
+func NewWithHandler(h slog.Handler) *Logger {
+	return &Logger{Level: Linfo, handler: h}
+}
+
+// -----------------------------------------
+
+// Handler returns a slog.Handler which writes log records to l, so that l
+// can be the backend of a slog.Logger:
+//
+//	logger := slog.New(log.Std.Handler())
+func (l *Logger) Handler() slog.Handler {
+	return &slogHandler{l: l}
+}
+
+type slogHandler struct {
+	l     *Logger
+	kvs   []interface{} // attrs added by WithAttrs
+	group string        // qualifier of keys added by WithGroup, e.g. "g1.g2."
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return levelOf(level) >= h.l.Level
+}
+
+func (h *slogHandler) Handle(_ context.Context, sr slog.Record) error {
+	kvs := h.kvs[:len(h.kvs):len(h.kvs)]
+	sr.Attrs(func(a slog.Attr) bool {
+		kvs = h.appendAttr(kvs, a)
+		return true
+	})
+	t := sr.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	r := h.l.newRecord(t, levelOf(sr.Level), "", sr.Message, kvs)
+	if sr.PC != 0 && r.Flag&(Lshortfile|Llongfile|Lintermediatefile) != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{sr.PC}).Next()
+		r.File, r.Line = frame.File, frame.Line
+	}
+	return h.l.write(r, sr.PC)
+}
+
+func (h *slogHandler) appendAttr(kvs []interface{}, a slog.Attr) []interface{} {
+	eachAttr(h.group, a, func(key string, val interface{}) {
+		kvs = append(kvs, key, val)
+	})
+	return kvs
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	kvs := h.kvs[:len(h.kvs):len(h.kvs)]
+	for _, a := range attrs {
+		kvs = h.appendAttr(kvs, a)
+	}
+	return &slogHandler{l: h.l, kvs: kvs, group: h.group}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{l: h.l, kvs: h.kvs, group: h.group + name + "."}
+}
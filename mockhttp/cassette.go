@@ -0,0 +1,438 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mockhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnmatched = errors.New("no matched interaction in cassette")
+	ErrExhausted = errors.New("matched interactions in cassette are all used")
+)
+
+// --------------------------------------------------------------------
+
+// Body represents a recorded request or response body. It is saved as text
+// if it is valid UTF-8, or else as base64.
+type Body []byte
+
+type bodyJSON struct {
+	Text   *string `json:"text,omitempty"`
+	Base64 string  `json:"base64,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p Body) MarshalJSON() ([]byte, error) {
+	var v bodyJSON
+	if utf8.Valid(p) {
+		text := string(p)
+		v.Text = &text
+	} else {
+		v.Base64 = base64.StdEncoding.EncodeToString(p)
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Body) UnmarshalJSON(data []byte) (err error) {
+	var v bodyJSON
+	if err = json.Unmarshal(data, &v); err != nil {
+		return
+	}
+	if v.Text != nil {
+		*p = Body(*v.Text)
+		return
+	}
+	*p, err = base64.StdEncoding.DecodeString(v.Base64)
+	return
+}
+
+// RecordedRequest represents a recorded HTTP request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse represents a recorded HTTP response.
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Interaction represents a recorded HTTP exchange.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Cassette represents a list of recorded HTTP exchanges.
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+	mutex        sync.Mutex
+}
+
+// LoadCassette loads a cassette from the specified file.
+func LoadCassette(file string) (*Cassette, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ret := new(Cassette)
+	if err = json.Unmarshal(b, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Save saves the cassette into the specified file.
+func (p *Cassette) Save(file string) error {
+	p.mutex.Lock()
+	b, err := json.MarshalIndent(p, "", "  ")
+	p.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0644)
+}
+
+// Add adds an interaction into the cassette.
+func (p *Cassette) Add(v *Interaction) {
+	p.mutex.Lock()
+	p.Interactions = append(p.Interactions, v)
+	p.mutex.Unlock()
+}
+
+// Hosts returns hosts of all recorded requests.
+func (p *Cassette) Hosts() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var hosts []string
+	found := make(map[string]bool)
+	for _, v := range p.Interactions {
+		u, err := url.Parse(v.Request.URL)
+		if err != nil || found[u.Host] {
+			continue
+		}
+		found[u.Host] = true
+		hosts = append(hosts, u.Host)
+	}
+	return hosts
+}
+
+// --------------------------------------------------------------------
+
+// DefaultRedactHeaders are headers redacted by a Recorder by default.
+var DefaultRedactHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+}
+
+// Redacted replaces values of redacted headers in a cassette.
+const Redacted = "REDACTED"
+
+// Recorder is a RoundTripper that records all HTTP exchanges through an
+// underlying RoundTripper into a cassette.
+type Recorder struct {
+	Transport http.RoundTripper // nil means http.DefaultTransport
+	Cassette  *Cassette
+
+	// RedactHeaders are request and response headers whose values are
+	// recorded as Redacted. nil means DefaultRedactHeaders; use an empty
+	// slice to record all headers as they are.
+	RedactHeaders []string
+}
+
+// NewRecorder creates a Recorder. If rt is nil, http.DefaultTransport is used.
+func NewRecorder(rt http.RoundTripper) *Recorder {
+	return &Recorder{Transport: rt, Cassette: new(Cassette)}
+}
+
+// Save saves recorded exchanges into the specified file.
+func (p *Recorder) Save(file string) error {
+	return p.Cassette.Save(file)
+}
+
+// RoundTrip executes a single HTTP transaction and records it.
+func (p *Recorder) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return
+		}
+		cp := *req
+		cp.Body = io.NopCloser(bytes.NewReader(reqBody))
+		req = &cp
+	}
+	rt := p.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if resp, err = rt.RoundTrip(req); err != nil {
+		return
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	p.Cassette.Add(&Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: p.redact(req.Header),
+			Body:   reqBody,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     p.redact(resp.Header),
+			Body:       respBody,
+		},
+	})
+	return
+}
+
+func (p *Recorder) redact(header http.Header) http.Header {
+	keys := p.RedactHeaders
+	if keys == nil {
+		keys = DefaultRedactHeaders
+	}
+	ret := header.Clone()
+	for _, key := range keys {
+		if vals := ret.Values(key); len(vals) > 0 {
+			redacted := make([]string, len(vals))
+			for i := range redacted {
+				redacted[i] = Redacted
+			}
+			ret[http.CanonicalHeaderKey(key)] = redacted
+		}
+	}
+	return ret
+}
+
+// --------------------------------------------------------------------
+
+// Matcher checks if a request (with its body) matches a recorded request.
+type Matcher = func(req *http.Request, body []byte, rec *RecordedRequest) bool
+
+// MatchMethod matches request methods.
+func MatchMethod(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return req.Method == rec.Method
+}
+
+// MatchURL matches request URLs.
+func MatchURL(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return req.URL.String() == rec.URL
+}
+
+// MatchBody matches hash of request bodies.
+func MatchBody(req *http.Request, body []byte, rec *RecordedRequest) bool {
+	return sha256.Sum256(body) == sha256.Sum256(rec.Body)
+}
+
+// MatchHeaders returns a Matcher that matches values of the specified headers.
+func MatchHeaders(keys ...string) Matcher {
+	return func(req *http.Request, body []byte, rec *RecordedRequest) bool {
+		for _, key := range keys {
+			a, b := req.Header.Values(key), rec.Header.Values(key)
+			if len(a) != len(b) {
+				return false
+			}
+			for i, v := range a {
+				if v != b[i] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll returns a Matcher that matches if all matchers match.
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(req *http.Request, body []byte, rec *RecordedRequest) bool {
+		for _, m := range matchers {
+			if !m(req, body, rec) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// DefaultMatcher matches request methods and URLs.
+var DefaultMatcher = MatchAll(MatchMethod, MatchURL)
+
+// --------------------------------------------------------------------
+
+// Replayer replays recorded HTTP exchanges of a cassette. It can be used as
+// a http.Handler or a http.RoundTripper.
+//
+// A request is answered by the first unused matched interaction. If all
+// matched interactions are used, the last of them is replayed again, or
+// ErrExhausted is returned in Strict mode.
+type Replayer struct {
+	// Match checks if a request matches a recorded one. nil means DefaultMatcher.
+	Match Matcher
+
+	// Strict makes unmatched requests fail with ErrUnmatched, and requests
+	// whose matched interactions are all used fail with ErrExhausted.
+	// Otherwise they are replied with 404 Not Found and the last matched
+	// interaction respectively.
+	Strict bool
+
+	cassette *Cassette
+	used     []bool
+	mutex    sync.Mutex
+}
+
+// NewReplayer creates a Replayer.
+func NewReplayer(c *Cassette, match Matcher, strict bool) *Replayer {
+	return &Replayer{Match: match, Strict: strict, cassette: c, used: make([]bool, len(c.Interactions))}
+}
+
+func (p *Replayer) find(req *http.Request) (*Interaction, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	match := p.Match
+	if match == nil {
+		match = DefaultMatcher
+	}
+
+	c := p.cassette
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if n := len(c.Interactions); n > len(p.used) {
+		p.used = append(p.used, make([]bool, n-len(p.used))...)
+	}
+	last := -1
+	for i, v := range c.Interactions {
+		if !match(req, body, &v.Request) {
+			continue
+		}
+		if !p.used[i] {
+			p.used[i] = true
+			return v, nil
+		}
+		last = i
+	}
+	if last >= 0 {
+		if p.Strict {
+			return nil, ErrExhausted
+		}
+		return c.Interactions[last], nil
+	}
+	return nil, ErrUnmatched
+}
+
+// Unused returns interactions that have not been replayed yet.
+func (p *Replayer) Unused() (ret []*Interaction) {
+	c := p.cassette
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, v := range c.Interactions {
+		if i >= len(p.used) || !p.used[i] {
+			ret = append(ret, v)
+		}
+	}
+	return
+}
+
+// RoundTrip replays a recorded HTTP transaction matching req.
+func (p *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	v, err := p.find(req)
+	if err != nil {
+		if err != ErrUnmatched || p.Strict {
+			return nil, err
+		}
+		v = &Interaction{Response: RecordedResponse{StatusCode: http.StatusNotFound}}
+	}
+	resp := &v.Response
+	ctlen := int64(len(resp.Body))
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(resp.StatusCode) + " " + http.StatusText(resp.StatusCode),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: ctlen,
+		Request:       req,
+	}, nil
+}
+
+// ServeHTTP replies a recorded response matching req.
+func (p *Replayer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	v, err := p.find(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp := &v.Response
+	h := w.Header()
+	for k, vals := range resp.Header {
+		h[k] = append([]string(nil), vals...)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// --------------------------------------------------------------------
+
+// Replay replays recorded HTTP exchanges of cassette c for all hosts in it,
+// and returns the Replayer.
+func (p *Transport) Replay(c *Cassette, match Matcher, strict bool) *Replayer {
+	r := NewReplayer(c, match, strict)
+	for _, host := range c.Hosts() {
+		p.ListenAndServe(host, r)
+	}
+	return r
+}
+
+// --------------------------------------------------------------------
@@ -0,0 +1,104 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mockhttp_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/x/mockhttp"
+)
+
+// --------------------------------------------------------------------
+
+func TestRecordReplay(t *testing.T) {
+	n := 0
+	backend := mockhttp.NewTransport()
+	backend.ListenAndServe("rec.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Count", strings.Repeat("x", n))
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + string(b)))
+	}))
+
+	rec := mockhttp.NewRecorder(backend)
+	c := &http.Client{Transport: rec}
+	get := func(c *http.Client, method, url, body string) (string, error) {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "secret")
+		resp, err := c.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return resp.Header.Get("X-Count") + ":" + string(b), err
+	}
+	for _, body := range []string{"a", "\xff\xfe"} {
+		if _, err := get(c, "POST", "http://rec.com/foo", body); err != nil {
+			t.Fatal("record:", err)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "cassette.json")
+	if err := rec.Save(file); err != nil {
+		t.Fatal("Save:", err)
+	}
+
+	cassette, err := mockhttp.LoadCassette(file)
+	if err != nil {
+		t.Fatal("LoadCassette:", err)
+	}
+	tr := mockhttp.NewTransport()
+	r := tr.Replay(cassette, mockhttp.MatchAll(mockhttp.DefaultMatcher, mockhttp.MatchBody), true)
+	c = &http.Client{Transport: tr}
+	if ret, err := get(c, "POST", "http://rec.com/foo", "\xff\xfe"); err != nil || ret != "xx:POST /foo \xff\xfe" {
+		t.Fatal("replay:", ret, err)
+	}
+	if unused := r.Unused(); len(unused) != 1 || string(unused[0].Request.Body) != "a" {
+		t.Fatal("Unused:", unused)
+	}
+	if ret, err := get(c, "POST", "http://rec.com/foo", "a"); err != nil || ret != "x:POST /foo a" {
+		t.Fatal("replay:", ret, err)
+	}
+	if _, err := get(c, "POST", "http://rec.com/foo", "b"); !errors.Is(err, mockhttp.ErrUnmatched) {
+		t.Fatal("strict replay:", err)
+	}
+	if _, err := get(c, "POST", "http://rec.com/foo", "a"); !errors.Is(err, mockhttp.ErrExhausted) {
+		t.Fatal("strict replay:", err)
+	}
+	r.Strict = false
+	if ret, err := get(c, "GET", "http://rec.com/foo", ""); err != nil || ret != ":" {
+		t.Fatal("non-strict replay:", ret, err)
+	}
+	if ret, err := get(c, "POST", "http://rec.com/foo", "a"); err != nil || ret != "x:POST /foo a" {
+		t.Fatal("non-strict replay:", ret, err)
+	}
+	for _, v := range cassette.Interactions {
+		if auth := v.Request.Header.Get("Authorization"); auth != mockhttp.Redacted {
+			t.Fatal("Authorization not redacted:", auth)
+		}
+	}
+	if n != 2 {
+		t.Fatal("backend called:", n)
+	}
+}
+
+// --------------------------------------------------------------------
@@ -0,0 +1,234 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mockhttp
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------
+
+// Fault represents faults injected into requests of a host or a route.
+type Fault struct {
+	// Rate is the probability that the fault applies to a request.
+	// 0 means always.
+	Rate float64
+
+	// Latency delays the request. It honors the request context cancellation.
+	Latency time.Duration
+
+	// Err, if not nil, is returned by RoundTrip as a transport error.
+	Err error
+
+	// Status, if not empty, makes the request replied with one of these
+	// status codes (chosen randomly) without calling the handler.
+	Status []int
+
+	// Truncated makes the response body end with io.ErrUnexpectedEOF after
+	// TruncateAt bytes.
+	Truncated  bool
+	TruncateAt int64
+
+	// DripSize, if positive, makes the response body delivered at most
+	// DripSize bytes per DripInterval.
+	DripSize     int
+	DripInterval time.Duration
+}
+
+type faultRule struct {
+	host   string
+	prefix string
+	fault  *Fault
+}
+
+type faultRules struct {
+	rules []faultRule
+	rnd   *rand.Rand
+	mutex sync.Mutex
+}
+
+// SetFault injects fault f into requests to host whose URL path has the
+// specified prefix (empty means all paths). If f is nil, the fault of host
+// and prefix is removed. Longer prefixes take precedence.
+func (p *Transport) SetFault(host, prefix string, f *Fault) *Transport {
+	p.faults.set(host, prefix, f)
+	return p
+}
+
+// ClearFaults removes all injected faults.
+func (p *Transport) ClearFaults() *Transport {
+	p.faults.mutex.Lock()
+	p.faults.rules = nil
+	p.faults.mutex.Unlock()
+	return p
+}
+
+// SetSeed sets the seed of random decisions made by faults (Rate, Status),
+// so tests are deterministic. The default seed is 1.
+func (p *Transport) SetSeed(seed int64) *Transport {
+	p.faults.mutex.Lock()
+	p.faults.rnd = rand.New(rand.NewSource(seed))
+	p.faults.mutex.Unlock()
+	return p
+}
+
+func (p *faultRules) set(host, prefix string, f *Fault) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, r := range p.rules {
+		if r.host == host && r.prefix == prefix {
+			if f == nil {
+				p.rules = append(p.rules[:i], p.rules[i+1:]...)
+			} else {
+				p.rules[i].fault = f
+			}
+			return
+		}
+	}
+	if f != nil {
+		p.rules = append(p.rules, faultRule{host, prefix, f})
+	}
+}
+
+func (p *faultRules) match(req *http.Request) (f *Fault) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	n := -1
+	for _, r := range p.rules {
+		if r.host == req.URL.Host && len(r.prefix) > n && strings.HasPrefix(req.URL.Path, r.prefix) {
+			f, n = r.fault, len(r.prefix)
+		}
+	}
+	return
+}
+
+func (p *faultRules) rand() *rand.Rand {
+	if p.rnd == nil {
+		p.rnd = rand.New(rand.NewSource(1))
+	}
+	return p.rnd
+}
+
+func (p *faultRules) hit(rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.rand().Float64() < rate
+}
+
+func (p *faultRules) intn(n int) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.rand().Intn(n)
+}
+
+func (p *faultRules) roundTrip(
+	req *http.Request, f *Fault, next func(req *http.Request) (*http.Response, error)) (*http.Response, error) {
+	if !p.hit(f.Rate) {
+		return next(req)
+	}
+	ctx := req.Context()
+	if f.Latency > 0 {
+		if err := sleep(ctx, f.Latency); err != nil {
+			closeBody(req)
+			return nil, err
+		}
+	}
+	if f.Err != nil {
+		closeBody(req)
+		return nil, f.Err
+	}
+	if n := len(f.Status); n > 0 {
+		closeBody(req)
+		code := f.Status[p.intn(n)]
+		return &http.Response{
+			Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+			StatusCode:    code,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        make(http.Header),
+			Body:          http.NoBody,
+			ContentLength: 0,
+			Request:       req,
+		}, nil
+	}
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	if f.Truncated || f.DripSize > 0 {
+		resp.Body = &faultBody{resp.Body, ctx, f, f.TruncateAt}
+	}
+	return resp, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// --------------------------------------------------------------------
+
+type faultBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	f      *Fault
+	remain int64
+}
+
+func (p *faultBody) Read(b []byte) (n int, err error) {
+	f := p.f
+	if f.Truncated {
+		if p.remain <= 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if int64(len(b)) > p.remain {
+			b = b[:p.remain]
+		}
+	}
+	if f.DripSize > 0 {
+		if len(b) > f.DripSize {
+			b = b[:f.DripSize]
+		}
+		if f.DripInterval > 0 {
+			if err = sleep(p.ctx, f.DripInterval); err != nil {
+				return
+			}
+		}
+	}
+	n, err = p.ReadCloser.Read(b)
+	p.remain -= int64(n)
+	return
+}
+
+// --------------------------------------------------------------------
@@ -0,0 +1,127 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mockhttp_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/mockhttp"
+)
+
+// --------------------------------------------------------------------
+
+func newFaultTransport() *mockhttp.Transport {
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("fault.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello world"))
+	}))
+	return tr
+}
+
+func TestFaultLatency(t *testing.T) {
+	tr := newFaultTransport().SetFault("fault.com", "/slow", &mockhttp.Fault{Latency: time.Hour})
+	c := &http.Client{Transport: tr}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://fault.com/slow/a", nil)
+	if _, err := c.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("latency:", err)
+	}
+	resp, err := c.Get("http://fault.com/fast")
+	if err != nil {
+		t.Fatal("no fault:", err)
+	}
+	resp.Body.Close()
+}
+
+func TestFaultErr(t *testing.T) {
+	errConn := errors.New("connection reset")
+	tr := newFaultTransport().SetFault("fault.com", "", &mockhttp.Fault{Err: errConn})
+	if _, err := (&http.Client{Transport: tr}).Get("http://fault.com/"); !errors.Is(err, errConn) {
+		t.Fatal("err:", err)
+	}
+	tr.SetFault("fault.com", "", nil)
+	if _, err := (&http.Client{Transport: tr}).Get("http://fault.com/"); err != nil {
+		t.Fatal("SetFault nil:", err)
+	}
+}
+
+func TestFaultBody(t *testing.T) {
+	tr := newFaultTransport()
+	tr.SetFault("fault.com", "/trunc", &mockhttp.Fault{Truncated: true, TruncateAt: 5})
+	tr.SetFault("fault.com", "/drip", &mockhttp.Fault{DripSize: 2, DripInterval: time.Millisecond})
+	c := &http.Client{Transport: tr}
+
+	resp, err := c.Get("http://fault.com/trunc")
+	if err != nil {
+		t.Fatal("trunc:", err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != io.ErrUnexpectedEOF || string(b) != "hello" {
+		t.Fatal("trunc body:", string(b), err)
+	}
+
+	resp, err = c.Get("http://fault.com/drip")
+	if err != nil {
+		t.Fatal("drip:", err)
+	}
+	buf := make([]byte, 64)
+	n, _ := resp.Body.Read(buf)
+	b, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if n != 2 || err != nil || string(b) != "llo world" {
+		t.Fatal("drip body:", n, string(b), err)
+	}
+}
+
+func TestFaultStatus(t *testing.T) {
+	codes := func(seed int64) (ret []int) {
+		tr := newFaultTransport().SetSeed(seed)
+		tr.SetFault("fault.com", "", &mockhttp.Fault{Rate: 0.5, Status: []int{500, 502, 503}})
+		c := &http.Client{Transport: tr}
+		for i := 0; i < 20; i++ {
+			resp, err := c.Get("http://fault.com/")
+			if err != nil {
+				t.Fatal("status:", err)
+			}
+			resp.Body.Close()
+			ret = append(ret, resp.StatusCode)
+		}
+		return
+	}
+	a, b := codes(7), codes(7)
+	ok := 0
+	for i, code := range a {
+		if code != b[i] {
+			t.Fatal("not deterministic:", a, b)
+		}
+		if code == 200 {
+			ok++
+		}
+	}
+	if ok == 0 || ok == len(a) {
+		t.Fatal("rate not honored:", a)
+	}
+}
+
+// --------------------------------------------------------------------
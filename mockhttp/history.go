@@ -0,0 +1,231 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mockhttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/test"
+)
+
+// --------------------------------------------------------------------
+
+// Call represents a request handled by a Transport.
+type Call struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte // filled as the request body is read by the handler
+
+	StatusCode int   // 0 if RoundTrip failed
+	Err        error // error returned by RoundTrip
+
+	Start    time.Time
+	Duration time.Duration // time until the response header is available
+}
+
+// newCall returns the call of req, and req with its body recorded into
+// the call.
+func (p *history) newCall(req *http.Request) (*Call, *http.Request) {
+	call := &Call{
+		Method: req.Method,
+		URL:    req.URL,
+		Header: req.Header.Clone(),
+		Start:  time.Now(),
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		cp := *req
+		cp.Body = &bodyRecorder{rc: req.Body, call: call, mutex: &p.mutex}
+		req = &cp
+	}
+	return call, req
+}
+
+// bodyRecorder records a request body into Call.Body as it's read, so
+// that the client can stream the body while the handler is running.
+type bodyRecorder struct {
+	rc    io.ReadCloser
+	call  *Call
+	mutex *sync.Mutex // the history mutex, guarding call.Body
+	once  sync.Once
+}
+
+func (p *bodyRecorder) Read(b []byte) (n int, err error) {
+	n, err = p.rc.Read(b)
+	p.mutex.Lock()
+	p.call.Body = append(p.call.Body, b[:n]...)
+	p.mutex.Unlock()
+	return
+}
+
+// Close records the rest of the body, like a http server draining it
+// after the handler returns, and closes it.
+func (p *bodyRecorder) Close() (err error) {
+	p.once.Do(func() {
+		io.Copy(io.Discard, p)
+		err = p.rc.Close()
+	})
+	return
+}
+
+func (p *Call) done(resp *http.Response, err error) *Call {
+	p.Duration = time.Since(p.Start)
+	if err != nil {
+		p.Err = err
+	} else {
+		p.StatusCode = resp.StatusCode
+	}
+	return p
+}
+
+type history struct {
+	calls []*Call
+	mutex sync.Mutex
+}
+
+func (p *history) add(call *Call) {
+	p.mutex.Lock()
+	p.calls = append(p.calls, call)
+	p.mutex.Unlock()
+}
+
+// Calls returns requests handled by the transport in order. They are
+// snapshots: the body of a request still being read isn't updated.
+func (p *Transport) Calls() []*Call {
+	h := &p.history
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	calls := make([]*Call, len(h.calls))
+	for i, call := range h.calls {
+		cp := *call
+		cp.Body = cp.Body[:len(cp.Body):len(cp.Body)]
+		calls[i] = &cp
+	}
+	return calls
+}
+
+// ResetCalls clears the call history.
+func (p *Transport) ResetCalls() {
+	h := &p.history
+	h.mutex.Lock()
+	h.calls = nil
+	h.mutex.Unlock()
+}
+
+// --------------------------------------------------------------------
+
+// Expectation checks calls to a route. Failures are reported through t.
+type Expectation struct {
+	t     test.CaseT
+	route string
+	calls []*Call
+}
+
+// Expect returns an Expectation of calls to the route specified by method
+// and pattern. An empty method matches any method. pattern is matched with
+// host+path of request URLs (eg. "foo.com/v1/bar"), and a trailing "*"
+// matches any suffix.
+func (p *Transport) Expect(t test.CaseT, method, pattern string) *Expectation {
+	prefix, wildcard := strings.CutSuffix(pattern, "*")
+	var calls []*Call
+	for _, c := range p.Calls() {
+		if method != "" && c.Method != method {
+			continue
+		}
+		if target := c.URL.Host + c.URL.Path; target == prefix || (wildcard && strings.HasPrefix(target, prefix)) {
+			calls = append(calls, c)
+		}
+	}
+	return &Expectation{t: t, route: strings.TrimSpace(method + " " + pattern), calls: calls}
+}
+
+// Calls returns calls to the route.
+func (p *Expectation) Calls() []*Call {
+	return p.calls
+}
+
+// Times expects the route is called n times.
+func (p *Expectation) Times(n int) *Expectation {
+	if len(p.calls) != n {
+		p.t.Helper()
+		p.t.Errorf("%s: called %d times, expected: %d", p.route, len(p.calls), n)
+	}
+	return p
+}
+
+// Called expects the route is called at least once.
+func (p *Expectation) Called() *Expectation {
+	if len(p.calls) == 0 {
+		p.t.Helper()
+		p.t.Errorf("%s: not called", p.route)
+	}
+	return p
+}
+
+// Header expects header key presents in all calls to the route.
+func (p *Expectation) Header(key string) *Expectation {
+	for i, c := range p.calls {
+		if _, ok := c.Header[http.CanonicalHeaderKey(key)]; !ok {
+			p.t.Helper()
+			p.t.Errorf("%s: call #%d has no header %s", p.route, i, key)
+		}
+	}
+	return p
+}
+
+// HeaderValue expects header key equals val in all calls to the route.
+func (p *Expectation) HeaderValue(key, val string) *Expectation {
+	for i, c := range p.calls {
+		if v := c.Header.Get(key); v != val {
+			p.t.Helper()
+			p.t.Errorf("%s: call #%d header %s = %q, expected: %q", p.route, i, key, v, val)
+		}
+	}
+	return p
+}
+
+// JSONBody expects request bodies of all calls to the route are JSON equal
+// to v, ignoring formatting and object key order.
+func (p *Expectation) JSONBody(v any) *Expectation {
+	p.t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		p.t.Fatal("json.Marshal:", err)
+	}
+	var expected any
+	json.Unmarshal(b, &expected)
+	for i, c := range p.calls {
+		var got any
+		if err := json.Unmarshal(c.Body, &got); err != nil {
+			p.t.Errorf("%s: call #%d body is not JSON: %v", p.route, i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, expected) {
+			p.t.Errorf("%s: call #%d body = %s, expected: %s", p.route, i, c.Body, b)
+		}
+	}
+	return p
+}
+
+// --------------------------------------------------------------------
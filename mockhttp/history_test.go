@@ -0,0 +1,84 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mockhttp_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/x/mockhttp"
+	"github.com/qiniu/x/reqid"
+	"github.com/qiniu/x/rpc"
+	"github.com/qiniu/x/test"
+)
+
+// --------------------------------------------------------------------
+
+type failRecorder struct {
+	test.CaseT
+	errs []string
+}
+
+func (p *failRecorder) Helper() {}
+
+func (p *failRecorder) Errorf(format string, args ...any) {
+	p.errs = append(p.errs, fmt.Sprintf(format, args...))
+}
+
+func TestHistory(t *testing.T) {
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("api.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	c := rpc.Client{Client: &http.Client{Transport: tr}}
+	ctx := reqid.NewContext(context.Background(), "abc")
+	var ret struct{}
+	if err := c.CallWithJson(ctx, &ret, "POST", "http://api.com/v1/foo", map[string]any{"a": 1, "b": "x"}); err != nil {
+		t.Fatal("CallWithJson:", err)
+	}
+	if err := c.Call(ctx, &ret, "GET", "http://api.com/v1/bar"); err != nil {
+		t.Fatal("Call:", err)
+	}
+	c.Call(ctx, &ret, "GET", "http://unknown.com/")
+
+	calls := tr.Calls()
+	if len(calls) != 3 || calls[0].StatusCode != 200 || calls[2].Err != mockhttp.ErrServerNotFound {
+		t.Fatal("Calls:", calls)
+	}
+	tt := test.NewT(t)
+	tr.Expect(tt, "POST", "api.com/v1/foo").Times(1).Header("X-Reqid").JSONBody(map[string]any{"b": "x", "a": 1})
+	tr.Expect(tt, "", "api.com/v1/*").Times(2).HeaderValue("X-Reqid", "abc")
+
+	fr := new(failRecorder)
+	tr.Expect(fr, "GET", "api.com/v1/*").Times(2).Header("X-Foo").JSONBody(1)
+	tr.Expect(fr, "PUT", "api.com/v1/foo").Called()
+	if errs := strings.Join(fr.errs, "\n"); len(fr.errs) != 4 ||
+		!strings.Contains(errs, "GET api.com/v1/*: called 1 times, expected: 2") ||
+		!strings.Contains(errs, "PUT api.com/v1/foo: not called") {
+		t.Fatal("expectation failures:", errs)
+	}
+
+	tr.ResetCalls()
+	if len(tr.Calls()) != 0 {
+		t.Fatal("ResetCalls failed")
+	}
+}
+
+// --------------------------------------------------------------------
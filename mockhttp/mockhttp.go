@@ -20,8 +20,6 @@ import (
 	"errors"
 	"io"
 	"net/http"
-	"net/http/httptest"
-	"strconv"
 
 	"github.com/qiniu/x/log"
 )
@@ -59,6 +57,8 @@ func (r *mockServerRequestBody) Close() error {
 type Transport struct {
 	route      map[string]http.Handler
 	remoteAddr string
+	faults     faultRules
+	history    history
 }
 
 // NewTransport creates a new mock RoundTripper object.
@@ -76,7 +76,9 @@ func (p *Transport) SetRemoteAddr(remoteAddr string) *Transport {
 }
 
 // ListenAndServe listens on a mock network address addr and handler
-// to handle requests on incoming connections.
+// to handle requests on incoming connections. If h also implements
+// http.RoundTripper (eg. a *Replayer), requests are passed to its RoundTrip
+// method directly.
 func (p *Transport) ListenAndServe(host string, h http.Handler) error {
 	if h == nil {
 		h = http.DefaultServeMux
@@ -88,38 +90,55 @@ func (p *Transport) ListenAndServe(host string, h http.Handler) error {
 // RoundTrip executes a single HTTP transaction, returning
 // a Response for the provided Request.
 func (p *Transport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	call, req := p.history.newCall(req)
+	resp, err = p.roundTrip(req)
+	p.history.add(call.done(resp, err))
+	return
+}
+
+func (p *Transport) roundTrip(req *http.Request) (resp *http.Response, err error) {
 	h := p.route[req.URL.Host]
 	if h == nil {
 		log.Warn("Server not found:", req.Host, "-", req.URL.Host)
+		closeBody(req)
 		return nil, ErrServerNotFound
 	}
+	if f := p.faults.match(req); f != nil {
+		return p.faults.roundTrip(req, f, func(req *http.Request) (*http.Response, error) {
+			return p.serve(req, h)
+		})
+	}
+	return p.serve(req, h)
+}
+
+func (p *Transport) serve(req *http.Request, h http.Handler) (resp *http.Response, err error) {
+	if rt, ok := h.(http.RoundTripper); ok { // eg. *Replayer
+		return rt.RoundTrip(req)
+	}
 
 	cp := *req
 	cp.RemoteAddr = p.remoteAddr
 	cp.Body = &mockServerRequestBody{req.Body, false}
 	req = &cp
 
-	rw := httptest.NewRecorder()
-	h.ServeHTTP(rw, req)
-
-	req.Body.Close()
+	rw := newResponseWriter(req)
+	go rw.serve(h)
 
-	ctlen := int64(-1)
-	if v := rw.Header().Get("Content-Length"); v != "" {
-		ctlen, _ = strconv.ParseInt(v, 10, 64)
+	ctx := req.Context()
+	select {
+	case <-rw.ready:
+		return rw.resp, rw.err
+	case <-ctx.Done():
+		rw.body.abort(ctx.Err())
+		return nil, ctx.Err()
 	}
+}
 
-	return &http.Response{
-		Status:           "",
-		StatusCode:       rw.Code,
-		Header:           rw.Header(),
-		Body:             io.NopCloser(rw.Body),
-		ContentLength:    ctlen,
-		TransferEncoding: nil,
-		Close:            false,
-		Trailer:          nil,
-		Request:          req,
-	}, nil
+// closeBody closes the request body, which is not passed to a handler.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
 }
 
 // --------------------------------------------------------------------
@@ -0,0 +1,313 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mockhttp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/qiniu/x/log"
+)
+
+// --------------------------------------------------------------------
+
+// bodyPipe is an unbounded in-memory pipe. Writes never block, and data
+// written before the client closes the body can still be read.
+type bodyPipe struct {
+	buf    bytes.Buffer
+	werr   error // set when the handler finishes
+	rerr   error // set when the request is canceled
+	closed bool  // set when the client closes the body
+	mutex  sync.Mutex
+	cond   sync.Cond
+}
+
+func newBodyPipe() *bodyPipe {
+	p := new(bodyPipe)
+	p.cond.L = &p.mutex
+	return p
+}
+
+func (p *bodyPipe) Read(b []byte) (n int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for {
+		if p.rerr != nil {
+			return 0, p.rerr
+		}
+		if p.buf.Len() > 0 {
+			return p.buf.Read(b)
+		}
+		if p.werr != nil {
+			return 0, p.werr
+		}
+		if p.closed {
+			return 0, io.EOF
+		}
+		p.cond.Wait()
+	}
+}
+
+func (p *bodyPipe) Write(b []byte) (n int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed || p.rerr != nil || p.werr != nil {
+		return 0, io.ErrClosedPipe
+	}
+	n, err = p.buf.Write(b)
+	p.cond.Broadcast()
+	return
+}
+
+func (p *bodyPipe) Close() error {
+	p.mutex.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+	return nil
+}
+
+func (p *bodyPipe) closeWrite(err error) {
+	p.mutex.Lock()
+	if p.werr == nil {
+		p.werr = err
+	}
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+}
+
+func (p *bodyPipe) abort(err error) {
+	p.mutex.Lock()
+	if p.rerr == nil && p.werr == nil {
+		p.rerr = err
+	}
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+}
+
+// --------------------------------------------------------------------
+
+// responseWriter pipes writes of a handler to the client response body as
+// they happen. The response is delivered (ready is closed) once its header is
+// written, the handler flushes, or the handler returns.
+type responseWriter struct {
+	req    *http.Request
+	header http.Header
+	resp   *http.Response
+	err    error
+
+	body *bodyPipe
+
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	wroteHeader bool
+	hijacked    bool
+}
+
+func newResponseWriter(req *http.Request) *responseWriter {
+	return &responseWriter{
+		req:    req,
+		header: make(http.Header),
+		body:   newBodyPipe(),
+		ready:  make(chan struct{}),
+	}
+}
+
+func (p *responseWriter) serve(h http.Handler) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-p.req.Context().Done():
+			p.body.abort(p.req.Context().Err())
+		case <-done:
+		}
+	}()
+	defer func() {
+		p.req.Body.Close()
+		if v := recover(); v != nil {
+			if v != http.ErrAbortHandler {
+				log.Error("mockhttp: panic serving", p.req.URL, "-", v)
+			}
+			if p.hijacked {
+				return
+			}
+			p.body.closeWrite(io.ErrUnexpectedEOF)
+			p.setReady(nil, fmt.Errorf("mockhttp: handler panic: %v", v))
+		}
+	}()
+	h.ServeHTTP(p, p.req)
+	if !p.hijacked {
+		p.req.Body.Close() // drain the request body before the response ends
+		p.finish()
+	}
+}
+
+func (p *responseWriter) setReady(resp *http.Response, err error) {
+	p.readyOnce.Do(func() {
+		p.resp, p.err = resp, err
+		close(p.ready)
+	})
+}
+
+func (p *responseWriter) finish() {
+	p.WriteHeader(http.StatusOK)
+	resp := p.resp
+	for k := range resp.Trailer {
+		resp.Trailer[k] = p.header[k]
+	}
+	for k, v := range p.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			if resp.Trailer == nil {
+				resp.Trailer = make(http.Header)
+			}
+			resp.Trailer[http.CanonicalHeaderKey(k[len(http.TrailerPrefix):])] = v
+		}
+	}
+	p.body.closeWrite(io.EOF)
+}
+
+// Header returns the response header map.
+func (p *responseWriter) Header() http.Header {
+	return p.header
+}
+
+// WriteHeader sends the response header with the provided status code.
+func (p *responseWriter) WriteHeader(code int) {
+	if p.wroteHeader || p.hijacked {
+		return
+	}
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		return // informational responses are not delivered to the client
+	}
+	p.wroteHeader = true
+
+	header := p.header.Clone()
+	var trailer http.Header
+	for _, v := range header.Values("Trailer") {
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				if trailer == nil {
+					trailer = make(http.Header)
+				}
+				trailer[http.CanonicalHeaderKey(k)] = nil
+			}
+		}
+	}
+	header.Del("Trailer")
+	for k := range header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			delete(header, k)
+		}
+	}
+
+	ctlen := int64(-1)
+	if v := header.Get("Content-Length"); v != "" {
+		ctlen, _ = strconv.ParseInt(v, 10, 64)
+	}
+	p.setReady(&http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          p.body,
+		ContentLength: ctlen,
+		Trailer:       trailer,
+		Request:       p.req,
+	}, nil)
+}
+
+// Write writes data to the client response body. Data are available to
+// the client immediately.
+func (p *responseWriter) Write(b []byte) (int, error) {
+	if p.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !p.wroteHeader {
+		h := p.header
+		if h.Get("Content-Type") == "" && h.Get("Transfer-Encoding") == "" {
+			h.Set("Content-Type", http.DetectContentType(b))
+		}
+		n, err := p.body.Write(b) // data are ready before the client sees the response
+		p.WriteHeader(http.StatusOK)
+		return n, err
+	}
+	return p.body.Write(b)
+}
+
+// Flush sends the response header if it is not sent yet. Data written
+// are always delivered to the client immediately.
+func (p *responseWriter) Flush() {
+	if !p.hijacked {
+		p.WriteHeader(http.StatusOK)
+	}
+}
+
+// Hijack lets the handler take over the connection. The client receives the
+// response that the handler writes into the connection, eg. a 101 Switching
+// Protocols response whose Body is an io.ReadWriteCloser.
+func (p *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if p.hijacked {
+		return nil, nil, http.ErrHijacked
+	}
+	if p.wroteHeader {
+		return nil, nil, fmt.Errorf("mockhttp: Hijack after WriteHeader")
+	}
+	p.hijacked = true
+	server, client := net.Pipe()
+	go func() {
+		br := bufio.NewReader(client)
+		resp, err := http.ReadResponse(br, p.req)
+		if err != nil {
+			client.Close()
+			p.setReady(nil, err)
+			return
+		}
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			resp.Body = &hijackedBody{br, client}
+		} else {
+			resp.Body = &hijackedBody{resp.Body, client}
+		}
+		p.setReady(resp, nil)
+	}()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+type hijackedBody struct {
+	io.Reader
+	conn net.Conn
+}
+
+func (p *hijackedBody) Write(b []byte) (int, error) {
+	return p.conn.Write(b)
+}
+
+func (p *hijackedBody) Close() error {
+	return p.conn.Close()
+}
+
+// --------------------------------------------------------------------
@@ -0,0 +1,175 @@
+/*
+ Copyright 2024 Qiniu Limited (qiniu.com)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package mockhttp_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/mockhttp"
+)
+
+// --------------------------------------------------------------------
+
+func TestStreaming(t *testing.T) {
+	next := make(chan struct{})
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("sse.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Trailer", "X-Checksum")
+		for i := 0; i < 3; i++ {
+			io.WriteString(w, "data: ping\n\n")
+			w.(http.Flusher).Flush()
+			<-next
+		}
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set(http.TrailerPrefix+"X-Extra", "def")
+	}))
+	resp, err := (&http.Client{Transport: tr}).Get("http://sse.com/events")
+	if err != nil {
+		t.Fatal("Get:", err)
+	}
+	defer resp.Body.Close()
+	if resp.Status != "200 OK" || resp.Header.Get("Trailer") != "" {
+		t.Fatal("resp:", resp.Status, resp.Header)
+	}
+	br := bufio.NewReader(resp.Body)
+	for i := 0; i < 3; i++ {
+		line, err := br.ReadString('\n')
+		if err != nil || line != "data: ping\n" {
+			t.Fatal("ReadString:", line, err)
+		}
+		br.ReadString('\n')
+		next <- struct{}{}
+	}
+	if _, err = io.ReadAll(br); err != nil {
+		t.Fatal("ReadAll:", err)
+	}
+	if resp.Trailer.Get("X-Checksum") != "abc" || resp.Trailer.Get("X-Extra") != "def" {
+		t.Fatal("Trailer:", resp.Trailer)
+	}
+}
+
+func TestHijack(t *testing.T) {
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("ws.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error("Hijack:", err)
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		line, _ := brw.ReadString('\n')
+		brw.WriteString("echo: " + line)
+		brw.Flush()
+	}))
+	req, _ := http.NewRequest("GET", "http://ws.com/", nil)
+	req.Header.Set("Upgrade", "echo")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal("RoundTrip:", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 101 || resp.Header.Get("Upgrade") != "echo" {
+		t.Fatal("resp:", resp.Status, resp.Header)
+	}
+	rw := resp.Body.(io.ReadWriteCloser)
+	io.WriteString(rw, "hello\n")
+	line, err := bufio.NewReader(rw).ReadString('\n')
+	if err != nil || line != "echo: hello\n" {
+		t.Fatal("echo:", line, err)
+	}
+}
+
+func TestPanicHandler(t *testing.T) {
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("panic.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	if _, err := (&http.Client{Transport: tr}).Get("http://panic.com/"); err == nil {
+		t.Fatal("Get: no error")
+	}
+}
+
+// --------------------------------------------------------------------
+
+func TestFullDuplex(t *testing.T) {
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("echo.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		br := bufio.NewReader(r.Body)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			io.WriteString(w, line)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pr, pw := io.Pipe()
+		go io.WriteString(pw, "ping\n")
+		req, _ := http.NewRequest("POST", "http://echo.com/", pr)
+		resp, err := (&http.Client{Transport: tr}).Do(req)
+		if err != nil {
+			t.Error("Do:", err)
+			return
+		}
+		defer resp.Body.Close()
+		br := bufio.NewReader(resp.Body)
+		for _, msg := range []string{"ping\n", "pong\n"} {
+			if line, err := br.ReadString('\n'); err != nil || line != msg {
+				t.Error("ReadString:", line, err)
+				return
+			}
+			if msg == "ping\n" {
+				// the call is published while the handler is reading
+				if calls := tr.Calls(); len(calls) != 1 || string(calls[0].Body) != "ping\n" {
+					t.Error("Calls while streaming:", calls)
+				}
+				polled := make(chan struct{})
+				go func() {
+					defer close(polled)
+					for i := 0; i < 100; i++ {
+						for _, c := range tr.Calls() {
+							_ = string(c.Body)
+						}
+					}
+				}()
+				defer func() { <-polled }()
+				go io.WriteString(pw, "pong\n")
+			}
+		}
+		pw.Close()
+		io.ReadAll(br)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("full-duplex request blocked")
+	}
+	if calls := tr.Calls(); len(calls) != 1 || string(calls[0].Body) != "ping\npong\n" {
+		t.Fatal("Calls:", calls)
+	}
+}
@@ -0,0 +1,234 @@
+package objcache
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/qiniu/x/objcache/lru"
+	"github.com/qiniu/x/objcache/policy"
+)
+
+const (
+	maxShards       = 64
+	minShardEntries = 64
+)
+
+// numShards returns the number of shards of a cache of cacheNum entries:
+// a power of two scaling with GOMAXPROCS, so that concurrent readers
+// rarely contend, but small enough that each shard holds at least
+// minShardEntries, so that the eviction policy stays meaningful.
+func numShards(cacheNum int) int {
+	n := 1
+	for n < 4*runtime.GOMAXPROCS(0) && n < maxShards {
+		n <<= 1
+	}
+	if cacheNum > 0 {
+		for n > 1 && cacheNum/n < minShardEntries {
+			n >>= 1
+		}
+	}
+	return n
+}
+
+// cache is a set of shards selected by key hash, each of which is a
+// policy.Cache with its own lock, expiration and stats.
+type cache struct {
+	shards []cacheShard
+	hash   func(Key) uint64
+}
+
+type cacheShard struct {
+	mu                 sync.RWMutex
+	lru                policy.Cache[Key, *item]
+	onEvict            policy.OnEvictFunc[Key, *item]
+	spill              func(key Key, it *item) // nil if not spilling
+	spilled            []spilledItem           // to spill after unlocking
+	cacheNum           int
+	expiring           bool // removing an expired item
+	nhit, nget, nevict int64
+}
+
+type spilledItem struct {
+	key Key
+	it  *item
+}
+
+// init initializes the cache. If spill is true, items evicted by capacity
+// are written to the disk cache of g.
+func (c *cache) init(g *Group, cacheNum int, spill bool, onEvicted ...OnEvictedFunc) {
+	var fn OnEvictedFunc
+	if onEvicted != nil {
+		fn = onEvicted[0]
+	}
+	c.initShards(g, numShards(cacheNum), cacheNum, spill, fn)
+}
+
+// initShards splits cacheNum entries into n shards, n being a power of two.
+func (c *cache) initShards(g *Group, n, cacheNum int, spill bool, fn OnEvictedFunc) {
+	c.shards = make([]cacheShard, n)
+	c.hash = policy.NewHash[Key]()
+	for i := range c.shards {
+		shardNum := cacheNum / n
+		if i < cacheNum%n {
+			shardNum++
+		}
+		c.shards[i].init(g, shardNum, spill, fn)
+	}
+}
+
+func (c *cache) shard(key Key) *cacheShard {
+	if len(c.shards) == 1 {
+		return &c.shards[0]
+	}
+	return &c.shards[c.hash(key)&uint64(len(c.shards)-1)]
+}
+
+func (c *cache) stats() (ret CacheStats) {
+	for i := range c.shards {
+		s := c.shards[i].stats()
+		ret.Items += s.Items
+		ret.Gets += s.Gets
+		ret.Hits += s.Hits
+		ret.Evictions += s.Evictions
+	}
+	return
+}
+
+func (c *cache) setPolicy(kind policy.Kind) {
+	for i := range c.shards {
+		c.shards[i].setPolicy(kind)
+	}
+}
+
+func (c *cache) add(key Key, it *item) {
+	c.shard(key).add(key, it)
+}
+
+func (c *cache) get(key Key) (it *item, ok bool) {
+	return c.shard(key).get(key)
+}
+
+func (c *cache) replace(key Key, old, fresh *item) bool {
+	return c.shard(key).replace(key, old, fresh)
+}
+
+func (c *cache) remove(key Key) {
+	c.shard(key).remove(key)
+}
+
+func (c *cache) invalidate(key Key) {
+	c.shard(key).invalidate(key)
+}
+
+func (c *cache) clear() {
+	for i := range c.shards {
+		c.shards[i].clear()
+	}
+}
+
+// -------------------------------------------------------------------------------------
+
+func (c *cacheShard) init(g *Group, cacheNum int, spill bool, fn OnEvictedFunc) {
+	c.onEvict = func(key Key, it *item, reason lru.EvictReason) {
+		if reason == lru.Removed && c.expiring {
+			reason = lru.Expired
+		}
+		if reason != lru.Removed {
+			c.nevict++
+			g.recordEviction(reason)
+		}
+		if c.spill != nil && reason == lru.Capacity {
+			c.spilled = append(c.spilled, spilledItem{key, it})
+		}
+		if fn != nil && it.err == nil {
+			fn(key, it.val)
+		}
+	}
+	if spill {
+		c.spill = g.spill
+	}
+	c.cacheNum = cacheNum
+	c.lru = policy.New(policy.LRU, cacheNum, c.onEvict)
+}
+
+func (c *cacheShard) stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return CacheStats{
+		Items:     int64(c.lru.Len()),
+		Gets:      c.nget,
+		Hits:      c.nhit,
+		Evictions: c.nevict,
+	}
+}
+
+func (c *cacheShard) setPolicy(kind policy.Kind) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Clear()
+	c.lru = policy.New(kind, c.cacheNum, c.onEvict)
+}
+
+func (c *cacheShard) add(key Key, it *item) {
+	c.mu.Lock()
+	c.lru.Add(key, it)
+	spilled := c.spilled
+	c.spilled = nil
+	c.mu.Unlock()
+	for _, e := range spilled {
+		c.spill(e.key, e.it)
+	}
+}
+
+func (c *cacheShard) get(key Key) (it *item, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nget++
+	it, ok = c.lru.Get(key)
+	if ok && it.expired() {
+		c.expiring = true
+		c.lru.Remove(key)
+		c.expiring = false
+		return nil, false
+	}
+	if ok {
+		c.nhit++
+	}
+	return
+}
+
+// replace replaces the item of key by fresh, if it's still old.
+func (c *cacheShard) replace(key Key, old, fresh *item) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if it, ok := c.lru.Peek(key); ok && it == old {
+		c.lru.Add(key, fresh)
+		return true
+	}
+	return false
+}
+
+func (c *cacheShard) remove(key Key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Remove(key)
+}
+
+func (c *cacheShard) invalidate(key Key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if it, ok := c.lru.Peek(key); ok {
+		if it.err != nil {
+			c.lru.Remove(key)
+		} else {
+			it.stale.Store(true)
+			it.refreshing.Store(false)
+		}
+	}
+}
+
+func (c *cacheShard) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Clear()
+}
@@ -0,0 +1,94 @@
+package objcache
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestShards(t *testing.T) {
+	for _, cacheNum := range []int{0, 10, 1000, 100000} {
+		n := numShards(cacheNum)
+		if n&(n-1) != 0 || n > maxShards || (cacheNum > 0 && n > 1 && cacheNum/n < minShardEntries) {
+			t.Fatalf("numShards(%d) = %d", cacheNum, n)
+		}
+	}
+	var c cache
+	c.initShards(&Group{name: "shards"}, 4, 1002, false, nil)
+	total := 0
+	for i := range c.shards {
+		total += c.shards[i].cacheNum
+	}
+	if total != 1002 {
+		t.Fatal("shard sizes:", total)
+	}
+	for i := 0; i < 2000; i++ {
+		c.add(i, newItem(i, nil, 0))
+	}
+	if it, ok := c.get(1999); !ok || it.val != 1999 {
+		t.Fatal("get:", it, ok)
+	}
+	if s := c.stats(); s.Items > 1002 || s.Evictions != 2000-s.Items || s.Gets != 1 || s.Hits != 1 {
+		t.Fatalf("stats: %+v", s)
+	}
+	c.clear()
+	if s := c.stats(); s.Items != 0 {
+		t.Fatalf("clear: %+v", s)
+	}
+
+	evicted := 0
+	c.initShards(&Group{name: "shards"}, 1, 10, false, func(key Key, value Value) {
+		evicted++
+	})
+	c.add("a", newItem(1, nil, 0))
+	c.add("a", newItem(2, nil, 0))
+	if it, ok := c.get("a"); !ok || it.val != 2 || evicted != 0 {
+		t.Fatal("replace:", it, ok, evicted)
+	}
+}
+
+func TestShardPointerKey(t *testing.T) {
+	type record struct{ N int }
+	var c cache
+	c.initShards(&Group{name: "shards"}, 16, 1000, false, nil)
+	keys := make([]*record, 100)
+	for i := range keys {
+		keys[i] = &record{i}
+		c.add(keys[i], newItem(i, nil, 0))
+	}
+	for i, key := range keys {
+		key.N += 1000 // mutating the target doesn't move the key
+		if it, ok := c.get(key); !ok || it.val != i {
+			t.Fatal("get mutated key:", i, it, ok)
+		}
+		c.remove(key)
+	}
+	if s := c.stats(); s.Items != 0 {
+		t.Fatalf("remove mutated keys: %+v", s)
+	}
+}
+
+// BenchmarkCacheGet compares a single shard with the default sharding of
+// concurrent gets. Run it with -cpu 1,2,4,8 to see how they scale across
+// GOMAXPROCS.
+func BenchmarkCacheGet(b *testing.B) {
+	const cacheNum = 10000
+	for _, n := range []int{1, numShards(cacheNum)} {
+		b.Run("shards="+strconv.Itoa(n), func(b *testing.B) {
+			var c cache
+			c.initShards(&Group{name: "bench"}, n, cacheNum, false, nil)
+			keys := make([]Key, cacheNum)
+			for i := range keys {
+				keys[i] = "key" + strconv.Itoa(i)
+				c.add(keys[i], newItem(i, nil, 0))
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				r := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					c.get(keys[r.Intn(cacheNum)])
+				}
+			})
+		})
+	}
+}
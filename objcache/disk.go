@@ -0,0 +1,308 @@
+package objcache
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/objcache/lru"
+)
+
+// DiskOptions are options of the on-disk second level cache of a group,
+// which survives restarts of the process.
+type DiskOptions struct {
+	// Dir is the directory of the cache files. It should be used by one
+	// group only.
+	Dir string
+
+	// MaxBytes is the maximum total size of the cache files before the
+	// least recently used one is removed. Zero means no limit.
+	MaxBytes int64
+
+	// Codec encodes values to the cache files. If nil, the codec of the
+	// group (see SetCodec) is used.
+	Codec Codec
+
+	// WriteAll makes all values loaded by the getter written to disk.
+	// Otherwise only values evicted from the main cache by capacity are.
+	WriteAll bool
+}
+
+// SetDiskCache enables the on-disk second level cache of the group. On a
+// miss of the in-memory caches, the value is read from disk, if any,
+// before it's loaded by a peer or the getter. Cache files left by a
+// previous process are indexed, and their values are read lazily.
+// Remove, Invalidate and Purge also remove the cache files. Only keys of
+// strings, numbers and booleans are cached on disk, since they can be
+// identified across restarts.
+//
+// An empty opts.Dir disables the disk cache.
+func (g *Group) SetDiskCache(opts DiskOptions) error {
+	var d *diskCache
+	if opts.Dir != "" {
+		if opts.Codec == nil {
+			opts.Codec = g.codecOf()
+			if opts.Codec == nil {
+				return ErrNoCodec
+			}
+		}
+		var err error
+		if d, err = openDiskCache(opts); err != nil {
+			return err
+		}
+	}
+	g.mu.Lock()
+	g.disk = d
+	g.mu.Unlock()
+	return nil
+}
+
+func (g *Group) codecOf() Codec {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.codec
+}
+
+func (g *Group) diskCache() *diskCache {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.disk
+}
+
+// getFromDisk looks up key in the disk cache, and caches the value found
+// in the main cache.
+func (g *Group) getFromDisk(key Key) (Value, bool) {
+	d := g.diskCache()
+	if d == nil {
+		return nil, false
+	}
+	it, ok := d.get(key)
+	if !ok {
+		return nil, false
+	}
+	g.Stats.DiskHits.Add(1)
+	g.mainCache.add(key, it)
+	return it.val, true
+}
+
+// spill writes an item evicted from the main cache to disk. It's called
+// after the shard lock is released, since it encodes and writes the item.
+func (g *Group) spill(key Key, it *item) {
+	if it.err != nil || it.stale.Load() || it.expired() {
+		return
+	}
+	if d := g.diskCache(); d != nil && !d.writeAll {
+		d.put(key, it)
+	}
+}
+
+// -------------------------------------------------------------------------------------
+
+const diskTempPrefix = ".tmp-"
+
+// diskCache is a directory of cache files, one per key, named by the hash
+// of the key. A file consists of the expiration time in Unix nanoseconds
+// (8 bytes, zero if it never expires), the length of the key (4 bytes),
+// the key and the encoded value.
+type diskCache struct {
+	dir      string
+	codec    Codec
+	writeAll bool
+
+	mu    sync.Mutex
+	index *lru.Cache[string, int64] // file name => file size
+}
+
+func openDiskCache(opts DiskOptions) (*diskCache, error) {
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	d := &diskCache{dir: opts.Dir, codec: opts.Codec, writeAll: opts.WriteAll}
+	d.index = lru.NewCache[string, int64](0)
+	d.index.MaxBytes = opts.MaxBytes
+	d.index.Sizer = func(name string, size int64) int64 {
+		return size
+	}
+	d.index.OnEvicted = func(name string, size int64) {
+		os.Remove(filepath.Join(d.dir, name))
+	}
+
+	type file struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	files := make([]file, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, diskTempPrefix) { // left by a crash
+			os.Remove(filepath.Join(d.dir, name))
+			continue
+		}
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			files = append(files, file{name, fi.Size(), fi.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, f := range files {
+		d.index.Add(f.name, f.size)
+	}
+	return d, nil
+}
+
+// keyString returns the identity of key on disk. Keys other than strings
+// are prefixed by their types, so that eg. 1 and "1" are different. It
+// returns false for keys which can't be identified by value, eg. pointers.
+func keyString(key Key) (string, bool) {
+	if s, ok := key.(string); ok {
+		return s, true
+	}
+	switch reflect.ValueOf(key).Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return fmt.Sprintf("%T:%v", key, key), true
+	}
+	return "", false
+}
+
+func diskName(skey string) string {
+	h := sha1.Sum([]byte(skey))
+	return hex.EncodeToString(h[:])
+}
+
+var (
+	errDiskEntry = errors.New("objcache: invalid disk cache entry")
+	errDiskKey   = errors.New("objcache: key type not supported by disk cache")
+)
+
+func (d *diskCache) get(key Key) (it *item, ok bool) {
+	skey, ok := keyString(key)
+	if !ok {
+		return
+	}
+	name := diskName(skey)
+	d.mu.Lock()
+	_, ok = d.index.Get(name)
+	d.mu.Unlock()
+	if !ok {
+		return
+	}
+	path := filepath.Join(d.dir, name)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var k string
+		it, k, err = d.decode(data)
+		if err == nil {
+			if k != skey { // a hash collision
+				return nil, false
+			}
+			if !it.expired() {
+				now := time.Now()
+				os.Chtimes(path, now, now) // keep the LRU order across restarts
+				return it, true
+			}
+		}
+	}
+	d.removeName(name)
+	return nil, false
+}
+
+func (d *diskCache) decode(data []byte) (it *item, key string, err error) {
+	if len(data) < 12 {
+		return nil, "", errDiskEntry
+	}
+	expire := int64(binary.LittleEndian.Uint64(data))
+	n := binary.LittleEndian.Uint32(data[8:])
+	data = data[12:]
+	if uint64(n) > uint64(len(data)) {
+		return nil, "", errDiskEntry
+	}
+	key = string(data[:n])
+	it = &item{}
+	if expire != 0 {
+		it.expire = time.Unix(0, expire)
+	}
+	if it.val, err = d.codec.Decode(data[n:]); err != nil {
+		return nil, "", err
+	}
+	return
+}
+
+func (d *diskCache) put(key Key, it *item) error {
+	skey, ok := keyString(key)
+	if !ok {
+		return errDiskKey
+	}
+	val, err := d.codec.Encode(it.val)
+	if err != nil {
+		return err
+	}
+	data := make([]byte, 12, 12+len(skey)+len(val))
+	if !it.expire.IsZero() {
+		binary.LittleEndian.PutUint64(data, uint64(it.expire.UnixNano()))
+	}
+	binary.LittleEndian.PutUint32(data[8:], uint32(len(skey)))
+	data = append(append(data, skey...), val...)
+
+	f, err := os.CreateTemp(d.dir, diskTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	name := diskName(skey)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.index.Remove(name)
+	if err = os.Rename(tmp, filepath.Join(d.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	d.index.Add(name, int64(len(data)))
+	return nil
+}
+
+func (d *diskCache) remove(key Key) {
+	if skey, ok := keyString(key); ok {
+		d.removeName(diskName(skey))
+	}
+}
+
+func (d *diskCache) removeName(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.index.Remove(name)
+}
+
+func (d *diskCache) clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.index.Clear()
+}
@@ -0,0 +1,184 @@
+package objcache
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type diskVal struct {
+	Name string
+	N    int
+}
+
+func newDiskGroup(t *testing.T, name string, cacheNum int, fills *int, opts DiskOptions) *Group {
+	g := NewGroup(name, cacheNum, func(ctx Context, key Key) (Value, error) {
+		*fills++
+		return diskVal{key.(string), len(key.(string))}, nil
+	})
+	if err := g.SetDiskCache(opts); err != nil {
+		t.Fatal("SetDiskCache:", err)
+	}
+	return g
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	opts := DiskOptions{Dir: dir, Codec: NewJSONCodec[diskVal](), WriteAll: true}
+	var fills int
+	g := newDiskGroup(t, "disk-1", 100, &fills, opts)
+	for _, key := range []string{"a", "bb", "ccc"} {
+		g.Get(nil, key)
+	}
+	g.Remove("bb")
+
+	// a restarted process reads values from disk
+	fills = 0
+	g = newDiskGroup(t, "disk-2", 100, &fills, opts)
+	val, err := g.Get(nil, "ccc")
+	if err != nil || val != (diskVal{"ccc", 3}) || fills != 0 || g.Stats.DiskHits.Get() != 1 {
+		t.Fatal("Get from disk:", val, err, fills)
+	}
+	if g.Get(nil, "bb"); fills != 1 {
+		t.Fatal("removed value should be loaded again")
+	}
+	g.Purge()
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatal("Purge should remove cache files:", len(entries))
+	}
+}
+
+func TestDiskSpill(t *testing.T) {
+	dir := t.TempDir()
+	var fills int
+	g := NewGroup("disk-spill", 2, func(ctx Context, key Key) (Value, error) {
+		fills++
+		return []byte(key.(string)), nil
+	})
+	g.SetCodec(BytesCodec)
+	if err := g.SetDiskCache(DiskOptions{Dir: dir, MaxBytes: 2 * (12 + 2*2)}); err != nil {
+		t.Fatal(err)
+	}
+	g.SetOptions(Options{TTL: time.Hour})
+	for _, key := range []string{"k1", "k2", "k3", "k4", "k5"} {
+		g.Get(nil, key)
+	}
+	// k1, k2 and k3 are evicted to disk, and k1 is then removed by MaxBytes
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatal("spilled files:", len(entries))
+	}
+	fills = 0
+	for _, key := range []string{"k3", "k1"} {
+		if val, err := g.Get(nil, key); err != nil || string(val.([]byte)) != key {
+			t.Fatal("Get:", key, val, err)
+		}
+	}
+	if fills != 1 || g.Stats.DiskHits.Get() != 1 {
+		t.Fatal("fills:", fills, g.Stats.DiskHits.Get())
+	}
+	// k4 and k5 are evicted to disk, which keeps them only
+	g.Invalidate("k4")
+	if d := g.diskCache(); d.index.Len() != 1 {
+		t.Fatal("Invalidate should remove the cache file")
+	}
+}
+
+func TestDiskRefresh(t *testing.T) {
+	var fills atomic.Int64
+	g := NewGroup("disk-refresh", 100, func(ctx Context, key Key) (Value, error) {
+		return diskVal{key.(string), int(fills.Add(1))}, nil
+	})
+	opts := DiskOptions{Dir: t.TempDir(), Codec: NewJSONCodec[diskVal](), WriteAll: true}
+	if err := g.SetDiskCache(opts); err != nil {
+		t.Fatal(err)
+	}
+	g.SetOptions(Options{TTL: time.Hour, RefreshAhead: 2 * time.Hour}) // always refresh
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		val, err := g.Get(nil, "a")
+		if err != nil {
+			t.Fatal("Get:", err)
+		}
+		if val.(diskVal).N > 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed from disk:", g.Stats.DiskHits.Get())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if g.Stats.DiskHits.Get() != 0 {
+		t.Fatal("DiskHits:", g.Stats.DiskHits.Get())
+	}
+}
+
+// lookupCodec looks up the group while encoding, which deadlocks if it's
+// called with a shard lock held.
+type lookupCodec struct {
+	Codec
+	g *Group
+}
+
+func (p lookupCodec) Encode(val Value) ([]byte, error) {
+	p.g.TryGet(string(val.([]byte)))
+	return p.Codec.Encode(val)
+}
+
+func TestDiskSpillUnlocked(t *testing.T) {
+	g := NewGroup("disk-spill-unlocked", 1, func(ctx Context, key Key) (Value, error) {
+		return []byte(key.(string)), nil
+	})
+	if err := g.SetDiskCache(DiskOptions{Dir: t.TempDir(), Codec: lookupCodec{BytesCodec, g}}); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		g.Get(nil, "a")
+		g.Get(nil, "b") // spills a
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("spill deadlocks")
+	}
+	if d := g.diskCache(); d.index.Len() != 1 {
+		t.Fatal("spilled files:", d.index.Len())
+	}
+}
+
+func TestDiskKeyTypes(t *testing.T) {
+	opts := DiskOptions{Dir: t.TempDir(), Codec: NewJSONCodec[diskVal](), WriteAll: true}
+	newGroup := func(name string, fills *int) *Group {
+		g := NewGroup(name, 100, func(ctx Context, key Key) (Value, error) {
+			*fills++
+			return diskVal{fmt.Sprintf("%T", key), *fills}, nil
+		})
+		if err := g.SetDiskCache(opts); err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	var fills int
+	g := newGroup("disk-keys-1", &fills)
+	type record struct{ N int }
+	for _, key := range []Key{1, "1", int64(1), &record{1}} {
+		g.Get(nil, key)
+	}
+	if entries, _ := os.ReadDir(opts.Dir); len(entries) != 3 {
+		t.Fatal("cache files:", len(entries))
+	}
+
+	fills = 0
+	g = newGroup("disk-keys-2", &fills)
+	for _, key := range []Key{1, "1", int64(1)} {
+		val, err := g.Get(nil, key)
+		if err != nil || val.(diskVal).Name != fmt.Sprintf("%T", key) {
+			t.Fatal("Get from disk:", key, val, err)
+		}
+	}
+	if g.Get(nil, &record{1}); fills != 1 || g.Stats.DiskHits.Get() != 3 {
+		t.Fatal("fills:", fills, g.Stats.DiskHits.Get())
+	}
+}
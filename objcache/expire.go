@@ -0,0 +1,136 @@
+package objcache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/qiniu/x/errors"
+)
+
+// Options are per-group options of cache expiration.
+type Options struct {
+	// TTL is the time to live of cached values. Zero means values never
+	// expire (but may still be evicted by the eviction policy).
+	TTL time.Duration
+
+	// NegativeTTL is the time to live of cached errors of the getter, for
+	// which IsNegative returns true. Zero means errors are not cached.
+	NegativeTTL time.Duration
+
+	// IsNegative reports whether an error of the getter can be cached.
+	// If nil, errors.IsNotFound is used.
+	IsNegative func(err error) bool
+
+	// RefreshAhead, if not zero, makes a Get within RefreshAhead before
+	// the value expires reload it in background, while the cached value is
+	// returned.
+	RefreshAhead time.Duration
+}
+
+func (p *Options) isNegative(err error) bool {
+	if p.IsNegative != nil {
+		return p.IsNegative(err)
+	}
+	return errors.IsNotFound(err)
+}
+
+// SetOptions sets the expiration options of the group. They apply to
+// values loaded afterwards.
+func (g *Group) SetOptions(opts Options) {
+	g.mu.Lock()
+	g.opts = opts
+	g.mu.Unlock()
+}
+
+func (g *Group) options() Options {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.opts
+}
+
+// Remove removes the cached value (or error) of key in this process.
+func (g *Group) Remove(key Key) {
+	g.mainCache.remove(key)
+	g.hotCache.remove(key)
+	if d := g.diskCache(); d != nil {
+		d.remove(key)
+	}
+}
+
+// Invalidate marks the cached value of key in this process as stale. The
+// stale value is still returned by the next Get, which reloads it in
+// background. A cached error, or a value in the disk cache, is removed.
+func (g *Group) Invalidate(key Key) {
+	g.mainCache.invalidate(key)
+	g.hotCache.invalidate(key)
+	if d := g.diskCache(); d != nil {
+		d.remove(key)
+	}
+}
+
+// Purge removes all cached values of the group in this process.
+func (g *Group) Purge() {
+	g.mainCache.clear()
+	g.hotCache.clear()
+	if d := g.diskCache(); d != nil {
+		d.clear()
+	}
+}
+
+// refreshAhead reloads the cached item of key in background if it's stale
+// or about to expire.
+func (g *Group) refreshAhead(key Key, it *item) {
+	if it.err != nil {
+		return
+	}
+	if !it.stale.Load() {
+		ahead := g.options().RefreshAhead
+		if ahead <= 0 || it.expire.IsZero() || time.Until(it.expire) > ahead {
+			return
+		}
+	}
+	if !it.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	g.Stats.Refreshes.Add(1)
+	go func() {
+		val, err, _ := g.loadGroup.do(nil, key, func(Context) (Value, error) {
+			return g.fetch(context.Background(), key, false)
+		})
+		if err != nil {
+			it.refreshing.Store(false) // retry on a later Get
+			return
+		}
+		// The fetched value isn't always cached where the refreshed item is,
+		// eg. a value of a peer is mirrored in the hot cache by chance.
+		fresh := newItem(val, nil, g.options().TTL)
+		if !g.mainCache.replace(key, it, fresh) {
+			g.hotCache.replace(key, it, fresh)
+		}
+	}()
+}
+
+// -------------------------------------------------------------------------------------
+
+// item is a cached value or error.
+type item struct {
+	val    Value
+	err    error     // non-nil for a cached error
+	expire time.Time // zero if the item never expires
+
+	stale      atomic.Bool
+	refreshing atomic.Bool
+}
+
+func newItem(val Value, err error, ttl time.Duration) *item {
+	it := &item{val: val, err: err}
+	if ttl > 0 {
+		it.expire = time.Now().Add(ttl)
+	}
+	return it
+}
+
+func (it *item) expired() bool {
+	return !it.expire.IsZero() && !time.Now().Before(it.expire)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/qiniu/x/errors"
+	"github.com/qiniu/x/objcache/consistenthash"
+)
+
+const (
+	defaultBasePath = "/_objcache/"
+	defaultReplicas = 50
+)
+
+// HTTPPool implements PeerPicker for a pool of HTTP peers. It is also the
+// http.Handler serving requests from other peers.
+//
+// The protocol: GET <peer><basePath><group>/<key>, where group and key are
+// path-escaped. The response body is the value encoded by the group's
+// codec. A key not found (see errors.IsNotFound) is answered with 404 Not
+// Found, which is a final result for the requesting peer.
+type HTTPPool struct {
+	// self is the base URL of the current peer, eg. "http://example.net:8000".
+	self string
+	opts HTTPPoolOptions
+
+	mu          sync.Mutex // guards peers and httpGetters
+	peers       *consistenthash.Map
+	httpGetters map[string]*httpGetter // keyed by eg. "http://10.0.0.2:8008"
+}
+
+// HTTPPoolOptions are the configurations of a HTTPPool.
+type HTTPPoolOptions struct {
+	// BasePath specifies the HTTP path that will serve objcache requests.
+	// If blank, it defaults to "/_objcache/".
+	BasePath string
+
+	// Replicas specifies the number of key replicas on the consistent hash.
+	// If blank, it defaults to 50.
+	Replicas int
+
+	// HashFn specifies the hash function of the consistent hash.
+	// If blank, it defaults to crc32.ChecksumIEEE.
+	HashFn consistenthash.Hash
+
+	// Client specifies the client to request peers.
+	// If nil, it defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewHTTPPool initializes an HTTP pool of peers. self is the base URL of
+// the current peer. opts may be nil. The pool serves requests of other
+// peers as a http.Handler, and is attached to groups by SetPeers, eg.
+//
+//	objcache.RegisterNewGroupHook(pool.RegisterGroup)
+func NewHTTPPool(self string, opts *HTTPPoolOptions) *HTTPPool {
+	p := &HTTPPool{self: self, httpGetters: make(map[string]*httpGetter)}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.BasePath == "" {
+		p.opts.BasePath = defaultBasePath
+	}
+	if p.opts.Replicas == 0 {
+		p.opts.Replicas = defaultReplicas
+	}
+	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	return p
+}
+
+// RegisterGroup attaches the pool to the group. It can be used as a hook
+// of RegisterNewGroupHook.
+func (p *HTTPPool) RegisterGroup(g *Group) {
+	g.SetPeers(p)
+}
+
+// Set updates the pool's list of peers.
+// Each peer value should be a valid base URL,
+// for example "http://example.net:8000".
+func (p *HTTPPool) Set(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	p.peers.Add(peers...)
+	p.httpGetters = make(map[string]*httpGetter, len(peers))
+	for _, peer := range peers {
+		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.opts.BasePath, client: p.opts.Client}
+	}
+}
+
+// PickPeer picks a peer according to key.
+func (p *HTTPPool) PickPeer(key string) (ProtoGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers.IsEmpty() {
+		return nil, false
+	}
+	if peer := p.peers.Get(key); peer != p.self {
+		return p.httpGetters[peer], true
+	}
+	return nil, false
+}
+
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, p.opts.BasePath) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	// Parse request.
+	parts := strings.SplitN(r.URL.EscapedPath()[len(p.opts.BasePath):], "/", 2)
+	if len(parts) != 2 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	groupName, err1 := url.PathUnescape(parts[0])
+	key, err2 := url.PathUnescape(parts[1])
+	if err1 != nil || err2 != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Fetch the value for this group/key.
+	group := GetGroup(groupName)
+	if group == nil {
+		http.Error(w, "no such group: "+groupName, http.StatusBadRequest)
+		return
+	}
+	group.Stats.ServerRequests.Add(1)
+	codec := group.getCodec()
+	if codec == nil {
+		http.Error(w, ErrNoCodec.Error(), http.StatusInternalServerError)
+		return
+	}
+	val, err := group.Get(r.Context(), key)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.IsNotFound(err) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	b, err := codec.Encode(val)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(b)
+}
+
+type httpGetter struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (h *httpGetter) Get(ctx Context, group string, key string) ([]byte, error) {
+	u := h.baseURL + url.PathEscape(group) + "/" + url.PathEscape(key)
+	cctx, ok := ctx.(context.Context)
+	if !ok {
+		cctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(cctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := h.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, &errors.NotFound{Category: "objcache: " + group + "/" + key}
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned: %v", res.Status)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %v", err)
+	}
+	return b, nil
+}
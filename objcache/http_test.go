@@ -0,0 +1,114 @@
+package objcache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	xerrors "github.com/qiniu/x/errors"
+	"github.com/qiniu/x/mockhttp"
+)
+
+func TestHTTPPool(t *testing.T) {
+	const self, other = "http://peer1.com", "http://peer2.com"
+	var localFills, remoteReqs int64
+	var failKey, missingKey, missingLocal string
+	tr := mockhttp.NewTransport()
+	pool := NewHTTPPool(self, &HTTPPoolOptions{Client: &http.Client{Transport: tr}})
+	pool.Set(self, other)
+	tr.ListenAndServe("peer1.com", pool)
+	tr.ListenAndServe("peer2.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&remoteReqs, 1)
+		key := r.URL.Path[strings.LastIndexByte(r.URL.Path, '/')+1:]
+		switch key {
+		case failKey:
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		case missingKey:
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "REMOTE:"+key)
+	}))
+
+	g := NewGroup("http-group", 100, func(ctx Context, key Key) (val Value, err error) {
+		atomic.AddInt64(&localFills, 1)
+		if key == missingLocal {
+			return nil, &xerrors.NotFound{Category: key.(string)}
+		}
+		return "LOCAL:" + key.(string), nil
+	})
+	pool.RegisterGroup(g)
+	g.SetCodec(StringCodec)
+
+	var localKey, remoteKey string
+	for i := 0; missingLocal == "" || missingKey == ""; i++ {
+		key := string(rune('a' + i))
+		switch {
+		case pool.peers.Get(key) == self:
+			if localKey == "" {
+				localKey = key
+			} else {
+				missingLocal = key
+			}
+		case remoteKey == "":
+			remoteKey = key
+		case failKey == "":
+			failKey = key
+		default:
+			missingKey = key
+		}
+		if i > 26 {
+			t.Fatal("can't find keys owned by both peers")
+		}
+	}
+
+	hotCacheRate = 1
+	defer func() { hotCacheRate = 10 }()
+	for i := 0; i < 2; i++ {
+		if v, err := g.Get(context.Background(), localKey); err != nil || v != "LOCAL:"+localKey {
+			t.Fatal("Get local key:", v, err)
+		}
+		if v, err := g.Get(context.Background(), remoteKey); err != nil || v != "REMOTE:"+remoteKey {
+			t.Fatal("Get remote key:", v, err)
+		}
+	}
+	if localFills != 1 || remoteReqs != 1 || g.Stats.PeerLoads.Get() != 1 || g.HotCacheStats().Items != 1 {
+		t.Fatal("fills:", localFills, remoteReqs, g.Stats.PeerLoads.Get(), g.HotCacheStats())
+	}
+
+	// a failed peer falls back to the local getter
+	if v, err := g.Get(nil, failKey); err != nil || v != "LOCAL:"+failKey || g.Stats.PeerErrors.Get() != 1 {
+		t.Fatal("Get fail:", v, err)
+	}
+
+	// a key not found by the owner is final, and cached negatively
+	g.SetOptions(Options{NegativeTTL: time.Minute})
+	for i := 0; i < 2; i++ {
+		if _, err := g.Get(nil, missingKey); !xerrors.IsNotFound(err) {
+			t.Fatal("Get missing:", err)
+		}
+	}
+	if localFills != 2 || remoteReqs != 3 || g.Stats.PeerErrors.Get() != 1 {
+		t.Fatal("Get missing:", localFills, remoteReqs, g.Stats.PeerErrors.Get())
+	}
+
+	// serve other peers
+	getter := &httpGetter{baseURL: self + defaultBasePath, client: &http.Client{Transport: tr}}
+	if b, err := getter.Get(nil, "http-group", localKey); err != nil || string(b) != "LOCAL:"+localKey {
+		t.Fatal("serve peer:", string(b), err)
+	}
+	if _, err := getter.Get(nil, "http-group", missingLocal); !xerrors.IsNotFound(err) {
+		t.Fatal("serve missing key:", err)
+	}
+	if _, err := getter.Get(nil, "no-group", localKey); err == nil || xerrors.IsNotFound(err) {
+		t.Fatal("serve unknown group:", err)
+	}
+	if g.Stats.ServerRequests.Get() != 2 {
+		t.Fatal("ServerRequests:", g.Stats.ServerRequests.Get())
+	}
+}
@@ -17,117 +17,263 @@ limitations under the License.
 // Package lru implements an LRU cache.
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"time"
+)
+
+// EvictReason tells why an entry is removed from the cache.
+type EvictReason int
+
+const (
+	// Capacity means the entry is evicted to keep the cache within
+	// MaxEntries or MaxBytes.
+	Capacity EvictReason = iota
+	// Expired means the entry has lived longer than its TTL.
+	Expired
+	// Removed means the entry is removed by Remove or Clear.
+	Removed
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case Capacity:
+		return "capacity"
+	case Expired:
+		return "expired"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+var timeNow = time.Now
 
 // Cache is an LRU cache. It is not safe for concurrent access.
-type Cache struct {
+type Cache[K comparable, V any] struct {
 	// MaxEntries is the maximum number of cache entries before
 	// an item is evicted. Zero means no limit.
 	MaxEntries int
 
+	// MaxBytes is the maximum total size of cache entries, measured by
+	// Sizer, before an item is evicted. Zero means no limit.
+	MaxBytes int64
+
+	// Sizer optionally returns the size of an entry in bytes.
+	Sizer func(key K, value V) int64
+
+	// TTL is the time to live of entries added by Add. Zero means entries
+	// never expire. Expired entries are removed lazily when accessed.
+	TTL time.Duration
+
 	// OnEvicted optionally specifies a callback function to be
 	// executed when an entry is purged from the cache.
-	OnEvicted func(key Key, value interface{})
+	OnEvicted func(key K, value V)
+
+	// OnEvict is like OnEvicted, but also reports why the entry is
+	// purged. Both are called if set.
+	OnEvict func(key K, value V, reason EvictReason)
 
 	ll    *list.List
-	cache map[interface{}]*list.Element
+	cache map[K]*list.Element
+	bytes int64
 }
 
 // A Key may be any value that is comparable. See http://golang.org/ref/spec#Comparison_operators
 type Key interface{}
 
-type entry struct {
-	key   Key
-	value interface{}
+type entry[K comparable, V any] struct {
+	key    K
+	value  V
+	size   int64
+	expire time.Time // zero if the entry never expires
 }
 
 // New creates a new Cache.
 // If maxEntries is zero, the cache has no limit and it's assumed
 // that eviction is done by the caller.
-func New(maxEntries int) *Cache {
-	return &Cache{
+func New(maxEntries int) *Cache[Key, interface{}] {
+	return NewCache[Key, interface{}](maxEntries)
+}
+
+// NewCache creates a new typed Cache. See New.
+func NewCache[K comparable, V any](maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
 		MaxEntries: maxEntries,
 		ll:         list.New(),
-		cache:      make(map[interface{}]*list.Element),
+		cache:      make(map[K]*list.Element),
 	}
 }
 
-// Add adds a value to the cache.
-func (c *Cache) Add(key Key, value interface{}) {
+// Add adds a value to the cache. It expires after c.TTL, if not zero.
+func (c *Cache[K, V]) Add(key K, value V) {
+	c.AddWithTTL(key, value, c.TTL)
+}
+
+// AddWithTTL adds a value to the cache, which expires after ttl. Zero ttl
+// means it never expires.
+func (c *Cache[K, V]) AddWithTTL(key K, value V, ttl time.Duration) {
 	if c.cache == nil {
-		c.cache = make(map[interface{}]*list.Element)
+		c.cache = make(map[K]*list.Element)
 		c.ll = list.New()
 	}
+	var expire time.Time
+	if ttl > 0 {
+		expire = timeNow().Add(ttl)
+	}
+	var size int64
+	if c.Sizer != nil {
+		size = c.Sizer(key, value)
+	}
 	if ee, ok := c.cache[key]; ok {
 		c.ll.MoveToFront(ee)
-		ee.Value.(*entry).value = value
-		return
+		e := ee.Value.(*entry[K, V])
+		c.bytes += size - e.size
+		e.value, e.size, e.expire = value, size, expire
+	} else {
+		ele := c.ll.PushFront(&entry[K, V]{key, value, size, expire})
+		c.cache[key] = ele
+		c.bytes += size
 	}
-	ele := c.ll.PushFront(&entry{key, value})
-	c.cache[key] = ele
-	if c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries {
+	c.evict()
+}
+
+// evict removes the oldest entries until the cache is within its limits.
+func (c *Cache[K, V]) evict() {
+	for (c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries) || (c.MaxBytes != 0 && c.bytes > c.MaxBytes) {
 		c.RemoveOldest()
 	}
 }
 
 // Get looks up a key's value from the cache.
-func (c *Cache) Get(key Key) (value interface{}, ok bool) {
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
 	if c.cache == nil {
 		return
 	}
 	if ele, hit := c.cache[key]; hit {
+		if c.expired(ele) {
+			return
+		}
 		c.ll.MoveToFront(ele)
-		return ele.Value.(*entry).value, true
+		return ele.Value.(*entry[K, V]).value, true
 	}
 	return
 }
 
+// Peek looks up a key's value from the cache without updating its
+// recency.
+func (c *Cache[K, V]) Peek(key K) (value V, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		if c.expired(ele) {
+			return
+		}
+		return ele.Value.(*entry[K, V]).value, true
+	}
+	return
+}
+
+// expired removes the entry if it's expired.
+func (c *Cache[K, V]) expired(e *list.Element) bool {
+	expire := e.Value.(*entry[K, V]).expire
+	if !expire.IsZero() && !timeNow().Before(expire) {
+		c.removeElement(e, Expired)
+		return true
+	}
+	return false
+}
+
 // Remove removes the provided key from the cache.
-func (c *Cache) Remove(key Key) {
+func (c *Cache[K, V]) Remove(key K) {
 	if c.cache == nil {
 		return
 	}
 	if ele, hit := c.cache[key]; hit {
-		c.removeElement(ele)
+		c.removeElement(ele, Removed)
 	}
 }
 
 // RemoveOldest removes the oldest item from the cache.
-func (c *Cache) RemoveOldest() {
+func (c *Cache[K, V]) RemoveOldest() {
 	if c.cache == nil {
 		return
 	}
 	ele := c.ll.Back()
 	if ele != nil {
-		c.removeElement(ele)
+		c.removeElement(ele, Capacity)
 	}
 }
 
-func (c *Cache) removeElement(e *list.Element) {
+func (c *Cache[K, V]) removeElement(e *list.Element, reason EvictReason) {
 	c.ll.Remove(e)
-	kv := e.Value.(*entry)
+	kv := e.Value.(*entry[K, V])
 	delete(c.cache, kv.key)
+	c.bytes -= kv.size
+	c.notify(kv, reason)
+}
+
+func (c *Cache[K, V]) notify(kv *entry[K, V], reason EvictReason) {
 	if c.OnEvicted != nil {
 		c.OnEvicted(kv.key, kv.value)
 	}
+	if c.OnEvict != nil {
+		c.OnEvict(kv.key, kv.value, reason)
+	}
+}
+
+// Keys returns the keys of unexpired entries, from the oldest to the
+// newest.
+func (c *Cache[K, V]) Keys() []K {
+	if c.cache == nil {
+		return nil
+	}
+	now := timeNow()
+	keys := make([]K, 0, c.ll.Len())
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		kv := ele.Value.(*entry[K, V])
+		if kv.expire.IsZero() || now.Before(kv.expire) {
+			keys = append(keys, kv.key)
+		}
+	}
+	return keys
+}
+
+// Resize changes the limits of the cache, and returns the number of
+// entries evicted.
+func (c *Cache[K, V]) Resize(maxEntries int, maxBytes int64) (evicted int) {
+	c.MaxEntries, c.MaxBytes = maxEntries, maxBytes
+	if c.cache == nil {
+		return
+	}
+	n := c.ll.Len()
+	c.evict()
+	return n - c.ll.Len()
 }
 
-// Len returns the number of items in the cache.
-func (c *Cache) Len() int {
+// Len returns the number of items in the cache, including expired ones
+// not removed yet.
+func (c *Cache[K, V]) Len() int {
 	if c.cache == nil {
 		return 0
 	}
 	return c.ll.Len()
 }
 
+// Bytes returns the total size of items in the cache, measured by Sizer.
+func (c *Cache[K, V]) Bytes() int64 {
+	return c.bytes
+}
+
 // Clear purges all stored items from the cache.
-func (c *Cache) Clear() {
-	if c.OnEvicted != nil {
+func (c *Cache[K, V]) Clear() {
+	if c.OnEvicted != nil || c.OnEvict != nil {
 		for _, e := range c.cache {
-			kv := e.Value.(*entry)
-			c.OnEvicted(kv.key, kv.value)
+			c.notify(e.Value.(*entry[K, V]), Removed)
 		}
 	}
 	c.ll = nil
 	c.cache = nil
+	c.bytes = 0
 }
@@ -18,7 +18,9 @@ package lru
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 type simpleStruct struct {
@@ -95,3 +97,66 @@ func TestEvict(t *testing.T) {
 		t.Fatalf("got %v in second evicted key; want %s", evictedKeys[1], "myKey1")
 	}
 }
+
+func TestGeneric(t *testing.T) {
+	var reasons []EvictReason
+	lru := NewCache[string, []byte](0)
+	lru.MaxBytes = 10
+	lru.Sizer = func(key string, value []byte) int64 { return int64(len(value)) }
+	lru.OnEvict = func(key string, value []byte, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}
+	lru.Add("a", make([]byte, 4))
+	lru.Add("b", make([]byte, 4))
+	if _, ok := lru.Peek("a"); !ok {
+		t.Fatal("Peek a: miss")
+	}
+	lru.Add("c", make([]byte, 4)) // a is the oldest: Peek doesn't touch it
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"b", "c"}) || lru.Bytes() != 8 {
+		t.Fatal("Keys:", keys, lru.Bytes())
+	}
+	lru.Add("c", make([]byte, 2))
+	if lru.Bytes() != 6 {
+		t.Fatal("Bytes after update:", lru.Bytes())
+	}
+	lru.Remove("b")
+	if n := lru.Resize(0, 1); n != 1 || lru.Len() != 0 {
+		t.Fatal("Resize:", n, lru.Len())
+	}
+	if !reflect.DeepEqual(reasons, []EvictReason{Capacity, Removed, Capacity}) {
+		t.Fatal("reasons:", reasons)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	var reasons []EvictReason
+	lru := NewCache[string, int](0)
+	lru.TTL = time.Minute
+	lru.OnEvict = func(key string, value int, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}
+	lru.Add("a", 1)
+	lru.AddWithTTL("b", 2, time.Hour)
+	lru.AddWithTTL("c", 3, 0)
+	now = now.Add(2 * time.Minute)
+	if keys := lru.Keys(); !reflect.DeepEqual(keys, []string{"b", "c"}) || lru.Len() != 3 {
+		t.Fatal("Keys:", keys, lru.Len())
+	}
+	if _, ok := lru.Get("a"); ok {
+		t.Fatal("Get expired: hit")
+	}
+	now = now.Add(24 * time.Hour)
+	if _, ok := lru.Peek("b"); ok {
+		t.Fatal("Peek expired: hit")
+	}
+	if v, ok := lru.Get("c"); !ok || v != 3 || lru.Len() != 1 {
+		t.Fatal("Get c:", v, ok, lru.Len())
+	}
+	if !reflect.DeepEqual(reasons, []EvictReason{Expired, Expired}) || reasons[0].String() != "expired" {
+		t.Fatal("reasons:", reasons)
+	}
+}
@@ -0,0 +1,111 @@
+package objcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/qiniu/x/event"
+	"github.com/qiniu/x/event/core"
+	"github.com/qiniu/x/event/export/metric"
+	"github.com/qiniu/x/event/keys"
+	"github.com/qiniu/x/event/label"
+	"github.com/qiniu/x/objcache/lru"
+)
+
+// Keys of objcache metric events.
+var (
+	KeyGroup  = keys.NewString("group", "name of the cache group")
+	KeyReason = keys.NewString("reason", "why a cache entry is evicted")
+
+	gets         = keys.NewInt64("gets", "Get requests")
+	cacheHits    = keys.NewInt64("cache_hits", "Get requests served by the cache")
+	loads        = keys.NewInt64("loads", "cache misses")
+	loadsDeduped = keys.NewInt64("loads_deduped", "loads after deduplication")
+	loadsShared  = keys.NewInt64("loads_shared", "loads sharing another in-flight load")
+	loadErrors   = keys.NewInt64("load_errors", "failed local loads")
+	peerLoads    = keys.NewInt64("peer_loads", "values loaded from peers")
+	peerErrors   = keys.NewInt64("peer_errors", "failed loads from peers")
+	evictions    = keys.NewInt64("evictions", "evicted cache entries")
+	loadLatency  = keys.NewFloat64("load_latency", "latency of loads in milliseconds")
+)
+
+var (
+	groupKeys = []label.Key{KeyGroup}
+
+	metricGets = metric.Scalar{
+		Name: "objcache_gets_total", Description: "Number of Get requests.", Keys: groupKeys,
+	}
+	metricCacheHits = metric.Scalar{
+		Name: "objcache_cache_hits_total", Description: "Number of Get requests served by the cache.", Keys: groupKeys,
+	}
+	metricLoads = metric.Scalar{
+		Name: "objcache_loads_total", Description: "Number of cache misses.", Keys: groupKeys,
+	}
+	metricLoadsDeduped = metric.Scalar{
+		Name: "objcache_loads_deduped_total", Description: "Number of loads after deduplication.", Keys: groupKeys,
+	}
+	metricLoadsShared = metric.Scalar{
+		Name: "objcache_loads_shared_total", Description: "Number of loads sharing another in-flight load.", Keys: groupKeys,
+	}
+	metricLoadErrors = metric.Scalar{
+		Name: "objcache_load_errors_total", Description: "Number of failed local loads.", Keys: groupKeys,
+	}
+	metricPeerLoads = metric.Scalar{
+		Name: "objcache_peer_loads_total", Description: "Number of values loaded from peers.", Keys: groupKeys,
+	}
+	metricPeerErrors = metric.Scalar{
+		Name: "objcache_peer_errors_total", Description: "Number of failed loads from peers.", Keys: groupKeys,
+	}
+	metricEvictions = metric.Scalar{
+		Name: "objcache_evictions_total", Description: "Number of evicted cache entries.", Keys: []label.Key{KeyGroup, KeyReason},
+	}
+	metricLoadLatency = metric.HistogramFloat64{
+		Name:        "objcache_load_latency_ms",
+		Description: "Latency of loads in milliseconds.",
+		Keys:        groupKeys,
+		Buckets:     []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+	}
+)
+
+// RegisterMetrics registers metrics of all groups to cfg. They are exported
+// once cfg.Exporter is set as the event exporter, eg.
+//
+//	var cfg metric.Config
+//	objcache.RegisterMetrics(&cfg)
+//	prom := prometheus.New()
+//	event.SetExporter(cfg.Exporter(prom.ProcessEvent))
+func RegisterMetrics(cfg *metric.Config) {
+	metricGets.SumInt64(cfg, gets)
+	metricCacheHits.SumInt64(cfg, cacheHits)
+	metricLoads.SumInt64(cfg, loads)
+	metricLoadsDeduped.SumInt64(cfg, loadsDeduped)
+	metricLoadsShared.SumInt64(cfg, loadsShared)
+	metricLoadErrors.SumInt64(cfg, loadErrors)
+	metricPeerLoads.SumInt64(cfg, peerLoads)
+	metricPeerErrors.SumInt64(cfg, peerErrors)
+	metricEvictions.SumInt64(cfg, evictions)
+	metricLoadLatency.Record(cfg, loadLatency)
+}
+
+// -------------------------------------------------------------------------------------
+
+func contextOf(ctx Context) context.Context {
+	if cctx, ok := ctx.(context.Context); ok {
+		return cctx
+	}
+	return context.Background()
+}
+
+// count records an event of key with value 1.
+func (g *Group) count(ctx Context, key *keys.Int64) {
+	core.Metric2(contextOf(ctx), KeyGroup.Of(g.name), key.Of(1))
+}
+
+func (g *Group) recordLatency(ctx Context, start time.Time) {
+	ms := float64(time.Since(start)) / float64(time.Millisecond)
+	core.Metric2(contextOf(ctx), KeyGroup.Of(g.name), loadLatency.Of(ms))
+}
+
+func (g *Group) recordEviction(reason lru.EvictReason) {
+	event.Metric(context.Background(), KeyGroup.Of(g.name), KeyReason.Of(reason.String()), evictions.Of(1))
+}
@@ -0,0 +1,44 @@
+package objcache
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/x/event"
+	"github.com/qiniu/x/event/export/metric"
+	"github.com/qiniu/x/event/export/prometheus"
+)
+
+func TestMetrics(t *testing.T) {
+	var cfg metric.Config
+	RegisterMetrics(&cfg)
+	prom := prometheus.New()
+	event.SetExporter(cfg.Exporter(prom.ProcessEvent))
+	defer event.SetExporter(nil)
+
+	g := NewGroup("metrics-group", 1, func(ctx Context, key Key) (val Value, err error) {
+		return key, nil
+	})
+	for _, key := range []string{"a", "a", "b"} {
+		g.Get(nil, key)
+	}
+	if stats := g.CacheStats(); stats.Evictions != 1 {
+		t.Fatal("CacheStats:", stats)
+	}
+
+	w := httptest.NewRecorder()
+	prom.Serve(w, httptest.NewRequest("GET", "/metrics", nil))
+	out := w.Body.String()
+	for _, want := range []string{
+		`objcache_gets_total{group="metrics-group"} 3`,
+		`objcache_cache_hits_total{group="metrics-group"} 1`,
+		`objcache_loads_deduped_total{group="metrics-group"} 2`,
+		`objcache_evictions_total{group="metrics-group",reason="capacity"} 1`,
+		`objcache_load_latency_ms_count{group="metrics-group"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %s in:\n%s", want, out)
+		}
+	}
+}
@@ -1,9 +1,15 @@
 package objcache
 
 import (
+	"math/rand"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/qiniu/x/errors"
 	"github.com/qiniu/x/objcache/lru"
+	"github.com/qiniu/x/objcache/policy"
 )
 
 // Key type.
@@ -34,12 +40,68 @@ func RegisterNewGroupHook(fn func(*Group)) {
 }
 
 // A Group is a cache namespace and associated data loaded spread over
-// a group of 1 or more machines.
+// a group of 1 or more machines (see SetPeers).
 type Group struct {
 	name string
 	get  GetterFunc
 
+	// mainCache is a cache of the keys for which this process
+	// (amongst its peers) is authoritative.
 	mainCache cache
+
+	// hotCache contains keys/values for which this peer is not
+	// authoritative (otherwise they would be in mainCache), but
+	// are popular enough to warrant mirroring in this process to
+	// avoid going over the network to fetch from a peer.
+	hotCache cache
+
+	mu    sync.RWMutex // guards peers, codec, opts and disk
+	peers PeerPicker
+	codec Codec
+	opts  Options
+	disk  *diskCache // see SetDiskCache
+
+	// loadGroup ensures that each key is only fetched once
+	// (either locally or remotely), regardless of the number of
+	// concurrent callers.
+	loadGroup flightGroup
+
+	// Stats are statistics on the group.
+	Stats Stats
+}
+
+// Stats are per-group statistics.
+type Stats struct {
+	Gets          AtomicInt // any Get request
+	CacheHits     AtomicInt // either cache was good
+	Loads         AtomicInt // (gets - cacheHits)
+	LoadsDeduped  AtomicInt // after singleflight
+	LoadsShared   AtomicInt // loads sharing the result of another in-flight load
+	PeerLoads     AtomicInt // either remote load or remote cache hit (not an error)
+	PeerErrors    AtomicInt
+	LocalLoads    AtomicInt // total good local loads
+	LocalLoadErrs AtomicInt // total bad local loads
+
+	ServerRequests AtomicInt // gets that came over the network from peers
+	Refreshes      AtomicInt // background reloads of stale or expiring values
+	DiskHits       AtomicInt // loads served by the disk cache
+}
+
+// An AtomicInt is an int64 to be accessed atomically.
+type AtomicInt int64
+
+// Add atomically adds n to i.
+func (i *AtomicInt) Add(n int64) {
+	atomic.AddInt64((*int64)(i), n)
+}
+
+// Get atomically gets the value of i.
+func (i *AtomicInt) Get() int64 {
+	return atomic.LoadInt64((*int64)(i))
+}
+
+func (i *AtomicInt) String() string {
+	return strconv.FormatInt(i.Get(), 10)
 }
 
 var (
@@ -58,11 +120,9 @@ func GetGroup(name string) *Group {
 
 // NewGroup creates a coordinated group-aware Getter from a Getter.
 //
-// The returned Getter tries (but does not guarantee) to run only one
-// Get call at once for a given key across an entire set of peer
-// processes. Concurrent callers both in the local process and in
-// other processes receive copies of the answer once the original Get
-// completes.
+// The returned Getter runs only one Get call at once for a given key in
+// the process. Concurrent callers receive the same answer once the
+// original Get completes.
 //
 // The group name must be unique for each getter.
 func NewGroup(name string, cacheNum int, getter GetterFunc, onEvicted ...OnEvictedFunc) *Group {
@@ -75,7 +135,12 @@ func NewGroup(name string, cacheNum int, getter GetterFunc, onEvicted ...OnEvict
 		name: name,
 		get:  getter,
 	}
-	g.mainCache.init(cacheNum, onEvicted...)
+	g.mainCache.init(g, cacheNum, true, onEvicted...)
+	if cacheNum > 0 {
+		g.hotCache.init(g, (cacheNum+7)/8, false)
+	} else {
+		g.hotCache.init(g, 0, false)
+	}
 	if newGroupHook != nil {
 		newGroupHook(g)
 	}
@@ -88,86 +153,189 @@ func (g *Group) Name() string {
 	return g.name
 }
 
-// Get func.
-func (g *Group) Get(ctx Context, key Key) (val Value, err error) {
-	val, ok := g.mainCache.get(key)
-	if ok {
+// SetPeers sets the peers of the group. A key that is a string and owned
+// by another peer is fetched from it, if the group has a codec (see
+// SetCodec); other keys are loaded locally by the getter.
+func (g *Group) SetPeers(peers PeerPicker) {
+	g.mu.Lock()
+	g.peers = peers
+	g.mu.Unlock()
+}
+
+// SetCodec sets the codec of values transferred between peers.
+func (g *Group) SetCodec(codec Codec) {
+	g.mu.Lock()
+	g.codec = codec
+	g.mu.Unlock()
+}
+
+func (g *Group) getCodec() Codec {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.codec
+}
+
+// pickPeer returns the peer that owns key, if any.
+func (g *Group) pickPeer(key Key) (peer ProtoGetter, codec Codec, ok bool) {
+	skey, isStr := key.(string)
+	if !isStr {
 		return
 	}
-	val, err = g.get(ctx, key)
-	if err == nil {
-		g.mainCache.add(key, val)
+	g.mu.RLock()
+	peers, codec := g.peers, g.codec
+	g.mu.RUnlock()
+	if peers == nil || codec == nil {
+		return
 	}
+	peer, ok = peers.PickPeer(skey)
 	return
 }
 
-// TryGet func.
-func (g *Group) TryGet(key Key) (val Value, ok bool) {
-	return g.mainCache.get(key)
+// Get returns the value of key from the cache, or loads it on a miss,
+// either from the peer owning the key or locally by the getter. Concurrent
+// misses of the same key share one load. If ctx is a context.Context, Get
+// returns ctx.Err() when ctx is done before the load completes, which
+// doesn't abort the load for other callers.
+func (g *Group) Get(ctx Context, key Key) (val Value, err error) {
+	g.Stats.Gets.Add(1)
+	g.count(ctx, gets)
+	if it, ok := g.lookupCache(key); ok {
+		g.Stats.CacheHits.Add(1)
+		g.count(ctx, cacheHits)
+		g.refreshAhead(key, it)
+		return it.val, it.err
+	}
+	return g.load(ctx, key)
 }
 
-// CacheStats returns stats about the provided cache within the group.
-func (g *Group) CacheStats() CacheStats {
-	return g.mainCache.stats()
+func (g *Group) load(ctx Context, key Key) (val Value, err error) {
+	g.Stats.Loads.Add(1)
+	g.count(ctx, loads)
+	val, err, shared := g.loadGroup.do(ctx, key, func(ctx Context) (Value, error) {
+		// Check the cache again because the key may be loaded by a load
+		// that just completed.
+		if it, ok := g.lookupCache(key); ok {
+			g.Stats.CacheHits.Add(1)
+			g.count(ctx, cacheHits)
+			return it.val, it.err
+		}
+		return g.fetch(ctx, key, true)
+	})
+	if shared {
+		g.Stats.LoadsShared.Add(1)
+		g.count(ctx, loadsShared)
+	}
+	return
 }
 
-// cache is a wrapper around an *lru.Cache that adds synchronization,
-// makes values always be ByteView, and counts the size of all keys and
-// values.
-type cache struct {
-	mu         sync.RWMutex
-	lru        *lru.Cache
-	nhit, nget int64
+// fetch loads key from the peer owning it, or locally by the getter, and
+// caches the result. If fromDisk is true, the disk cache is looked up
+// first; a refresh doesn't, since the value on disk may be as old as the
+// one being refreshed.
+func (g *Group) fetch(ctx Context, key Key, fromDisk bool) (Value, error) {
+	g.Stats.LoadsDeduped.Add(1)
+	g.count(ctx, loadsDeduped)
+	start := time.Now()
+	defer g.recordLatency(ctx, start)
+	if fromDisk {
+		if val, ok := g.getFromDisk(key); ok {
+			return val, nil
+		}
+	}
+	opts := g.options()
+	if peer, codec, ok := g.pickPeer(key); ok {
+		val, err := g.getFromPeer(ctx, peer, codec, key.(string), opts.TTL)
+		if err == nil {
+			g.Stats.PeerLoads.Add(1)
+			g.count(ctx, peerLoads)
+			return val, nil
+		}
+		if errors.IsNotFound(err) { // the owner's answer is final
+			if opts.NegativeTTL > 0 {
+				g.hotCache.add(key, newItem(nil, err, opts.NegativeTTL))
+			}
+			return nil, err
+		}
+		g.Stats.PeerErrors.Add(1) // fall back to load locally
+		g.count(ctx, peerErrors)
+	}
+	val, err := g.get(ctx, key)
+	if err != nil {
+		g.Stats.LocalLoadErrs.Add(1)
+		g.count(ctx, loadErrors)
+		if opts.NegativeTTL > 0 && opts.isNegative(err) {
+			g.mainCache.add(key, newItem(nil, err, opts.NegativeTTL))
+		}
+		return nil, err
+	}
+	g.Stats.LocalLoads.Add(1)
+	it := newItem(val, nil, opts.TTL)
+	g.mainCache.add(key, it)
+	if d := g.diskCache(); d != nil && d.writeAll {
+		d.put(key, it)
+	}
+	return val, nil
 }
 
-func (c *cache) stats() CacheStats {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return CacheStats{
-		Items: c.itemsLocked(),
-		Gets:  c.nget,
-		Hits:  c.nhit,
+// hotCacheRate is the inverse probability of mirroring a value fetched
+// from a peer in the hot cache.
+var hotCacheRate = 10
+
+func (g *Group) getFromPeer(ctx Context, peer ProtoGetter, codec Codec, key string, ttl time.Duration) (Value, error) {
+	b, err := peer.Get(ctx, g.name, key)
+	if err != nil {
+		return nil, err
+	}
+	val, err := codec.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	// Mirror only some percentage of values, so that the hot cache is
+	// mostly populated by popular keys.
+	if rand.Intn(hotCacheRate) == 0 {
+		g.hotCache.add(key, newItem(val, nil, ttl))
 	}
+	return val, nil
 }
 
-func (c *cache) init(cacheNum int, onEvicted ...OnEvictedFunc) {
-	c.lru = lru.New(cacheNum)
-	if onEvicted != nil {
-		c.lru.OnEvicted = onEvicted[0]
+func (g *Group) lookupCache(key Key) (it *item, ok bool) {
+	if it, ok = g.mainCache.get(key); ok {
+		return
 	}
+	return g.hotCache.get(key)
 }
 
-func (c *cache) add(key Key, value Value) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.lru.Add(key, value)
+// TryGet func.
+func (g *Group) TryGet(key Key) (val Value, ok bool) {
+	it, ok := g.lookupCache(key)
+	if !ok || it.err != nil {
+		return nil, false
+	}
+	return it.val, true
 }
 
-func (c *cache) get(key Key) (value Value, ok bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.nget++
-	v, ok := c.lru.Get(key)
-	if ok {
-		value = v.(Value)
-		c.nhit++
-	}
-	return
+// CacheStats returns stats about the main cache within the group.
+func (g *Group) CacheStats() CacheStats {
+	return g.mainCache.stats()
 }
 
-func (c *cache) items() int64 {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	return c.itemsLocked()
+// SetPolicy sets the eviction policy of the group's caches, which is
+// policy.LRU by default. Values cached so far are purged.
+func (g *Group) SetPolicy(kind policy.Kind) {
+	g.mainCache.setPolicy(kind)
+	g.hotCache.setPolicy(kind)
 }
 
-func (c *cache) itemsLocked() int64 {
-	return int64(c.lru.Len())
+// HotCacheStats returns stats about the hot cache within the group, which
+// mirrors popular values owned by other peers.
+func (g *Group) HotCacheStats() CacheStats {
+	return g.hotCache.stats()
 }
 
 // CacheStats are returned by stats accessors on Group.
 type CacheStats struct {
-	Items int64
-	Gets  int64
-	Hits  int64
+	Items     int64
+	Gets      int64
+	Hits      int64
+	Evictions int64 // entries evicted by capacity or expiration
 }
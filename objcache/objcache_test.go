@@ -1,9 +1,16 @@
 package objcache
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
+
+	xerrors "github.com/qiniu/x/errors"
+	"github.com/qiniu/x/objcache/policy"
 )
 
 var (
@@ -59,3 +66,273 @@ func TestGetVal(t *testing.T) {
 		t.Errorf("key got %q; want %q", val, want)
 	}
 }
+
+func TestSingleflight(t *testing.T) {
+	release := make(chan struct{})
+	var fills int64
+	g := NewGroup("singleflight-group", 0, func(ctx Context, key Key) (val Value, err error) {
+		<-release
+		atomic.AddInt64(&fills, 1)
+		if key == "err" {
+			return nil, errors.New("load failed")
+		}
+		return stringVal("ECHO:" + key.(string)), nil
+	})
+
+	var wg sync.WaitGroup
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := g.Get(nil, "key")
+			if err == nil && val != stringVal("ECHO:key") {
+				err = fmt.Errorf("unexpected value: %v", val)
+			}
+			errs <- err
+		}()
+	}
+	for g.Stats.Loads.Get() != n {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a canceled waiter returns early, and the load goes on
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.Get(ctx, "key"); err != context.Canceled {
+		t.Fatal("Get canceled:", err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fills != 1 || g.Stats.LoadsDeduped.Get() != 1 || g.Stats.LoadsShared.Get() != n {
+		t.Fatal("fills:", fills, "deduped:", g.Stats.LoadsDeduped.Get(), "shared:", g.Stats.LoadsShared.Get())
+	}
+	if _, err := g.Get(context.Background(), "err"); err == nil || g.Stats.LocalLoadErrs.Get() != 1 {
+		t.Fatal("Get err:", err)
+	}
+	if _, ok := g.TryGet("err"); ok {
+		t.Fatal("error is cached")
+	}
+}
+
+func TestSingleflightPanic(t *testing.T) {
+	release := make(chan struct{})
+	var panicking atomic.Bool
+	panicking.Store(true)
+	g := NewGroup("singleflight-panic-group", 0, func(ctx Context, key Key) (val Value, err error) {
+		<-release
+		if panicking.Load() {
+			panic("load panicked")
+		}
+		return stringVal("ECHO:" + key.(string)), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	const n = 5
+	panics := make(chan any, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(ctx Context) {
+			defer wg.Done()
+			defer func() { panics <- recover() }()
+			g.Get(ctx, "key")
+		}(ctx)
+		ctx = nil // others wait without a context
+	}
+	for g.Stats.Loads.Get() != n {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(panics)
+	for r := range panics {
+		if e, ok := r.(*panicError); !ok || e.value != "load panicked" {
+			t.Fatal("recovered:", r)
+		}
+	}
+
+	panicking.Store(false)
+	if val, err := g.Get(nil, "key"); err != nil || val != stringVal("ECHO:key") {
+		t.Fatal("Get after panic:", val, err)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	var fills int64
+	g := NewGroup("expire-group", 0, func(ctx Context, key Key) (val Value, err error) {
+		n := atomic.AddInt64(&fills, 1)
+		if key == "missing" {
+			return nil, &xerrors.NotFound{Category: "key"}
+		}
+		return fmt.Sprint(key, n), nil
+	})
+	g.SetOptions(Options{TTL: 200 * time.Millisecond, NegativeTTL: time.Minute, RefreshAhead: 150 * time.Millisecond})
+
+	waitRefresh := func(want int64) {
+		for atomic.LoadInt64(&fills) < want {
+			time.Sleep(time.Millisecond)
+		}
+		for {
+			g.loadGroup.mu.Lock()
+			_, loading := g.loadGroup.m["a"]
+			g.loadGroup.mu.Unlock()
+			if !loading {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	get := func(key string, want Value) {
+		t.Helper()
+		if v, err := g.Get(nil, key); err != nil || v != want {
+			t.Fatal("Get:", key, v, err, "want", want)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := g.Get(nil, "missing"); !xerrors.IsNotFound(err) || atomic.LoadInt64(&fills) != 1 {
+			t.Fatal("Get missing:", err, fills)
+		}
+	}
+	get("a", "a2")
+	get("a", "a2")
+	if atomic.LoadInt64(&fills) != 2 {
+		t.Fatal("refreshed too early")
+	}
+	time.Sleep(100 * time.Millisecond)
+	get("a", "a2") // triggers refresh-ahead
+	waitRefresh(3)
+	get("a", "a3")
+
+	g.Invalidate("a")
+	get("a", "a3") // stale, triggers refresh
+	waitRefresh(4)
+	get("a", "a4")
+	if g.Stats.Refreshes.Get() != 2 {
+		t.Fatal("Refreshes:", g.Stats.Refreshes.Get())
+	}
+
+	g.Remove("a")
+	get("a", "a5")
+	time.Sleep(250 * time.Millisecond)
+	get("a", "a6") // expired
+
+	g.Purge()
+	if _, err := g.Get(nil, "missing"); !xerrors.IsNotFound(err) || atomic.LoadInt64(&fills) != 7 {
+		t.Fatal("Get missing after Purge:", err, fills)
+	}
+}
+
+func TestRefreshError(t *testing.T) {
+	var fills int64
+	var failing atomic.Bool
+	g := NewGroup("refresh-error-group", 0, func(ctx Context, key Key) (val Value, err error) {
+		if failing.Load() {
+			return nil, errors.New("load failed")
+		}
+		return fmt.Sprint(key, atomic.AddInt64(&fills, 1)), nil
+	})
+	if v, err := g.Get(nil, "a"); err != nil || v != "a1" {
+		t.Fatal("Get:", v, err)
+	}
+	g.Invalidate("a")
+	failing.Store(true)
+	for deadline := time.Now().Add(5 * time.Second); g.Stats.LocalLoadErrs.Get() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("failed refresh isn't retried:", g.Stats.Refreshes.Get(), g.Stats.LocalLoadErrs.Get())
+		}
+		if v, err := g.Get(nil, "a"); err != nil || v != "a1" {
+			t.Fatal("Get stale:", v, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	failing.Store(false)
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		if v, _ := g.Get(nil, "a"); v == "a2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale value isn't refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// fakePeer owns all keys, and serves values by version.
+type fakePeer struct {
+	version atomic.Int64
+}
+
+func (p *fakePeer) PickPeer(key string) (ProtoGetter, bool) {
+	return p, true
+}
+
+func (p *fakePeer) Get(ctx Context, group string, key string) ([]byte, error) {
+	return []byte(fmt.Sprint(key, ":v", p.version.Load())), nil
+}
+
+func TestRefreshPeer(t *testing.T) {
+	peer := &fakePeer{}
+	peer.version.Store(1)
+	g := NewGroup("refresh-peer-group", 0, func(ctx Context, key Key) (Value, error) {
+		return nil, errors.New("not owned")
+	})
+	g.SetPeers(peer)
+	g.SetCodec(StringCodec)
+
+	hotCacheRate = 1
+	if v, err := g.Get(nil, "a"); err != nil || v != "a:v1" || g.HotCacheStats().Items != 1 {
+		t.Fatal("Get:", v, err)
+	}
+	hotCacheRate = 1 << 30 // the refreshed value isn't mirrored
+	defer func() { hotCacheRate = 10 }()
+	peer.version.Store(2)
+	g.Invalidate("a")
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		v, err := g.Get(nil, "a")
+		if err != nil {
+			t.Fatal("Get:", err)
+		}
+		if v == "a:v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale value isn't refreshed:", v, g.Stats.Refreshes.Get())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	for _, kind := range []policy.Kind{policy.LFU, policy.ARC, policy.TinyLFU} {
+		var fills, evicted int64
+		g := NewGroup("policy-"+kind.String(), 10, func(ctx Context, key Key) (Value, error) {
+			fills++
+			return stringVal(fmt.Sprint(key)), nil
+		}, func(key Key, val Value) {
+			evicted++
+		})
+		g.Get(nil, "hot")
+		g.SetPolicy(kind)
+		if evicted != 1 || g.CacheStats().Items != 0 {
+			t.Fatalf("%v: SetPolicy should purge the cache", kind)
+		}
+		for i := 0; i < 100; i++ {
+			g.Get(nil, "hot")
+			g.Get(nil, i)
+		}
+		if fills != 102 || g.CacheStats().Items != 10 {
+			t.Fatalf("%v: fills %d, stats %+v", kind, fills, g.CacheStats())
+		}
+	}
+}
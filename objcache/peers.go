@@ -0,0 +1,120 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+)
+
+// ProtoGetter is the interface that must be implemented by a peer.
+type ProtoGetter interface {
+	// Get returns the encoded value of key in the named group. If key
+	// doesn't exist, it returns an error satisfying errors.IsNotFound,
+	// which is final: the key isn't loaded locally instead.
+	Get(ctx Context, group string, key string) ([]byte, error)
+}
+
+// PeerPicker is the interface that must be implemented to locate
+// the peer that owns a specific key.
+type PeerPicker interface {
+	// PickPeer returns the peer that owns the specific key and true to
+	// indicate that a remote peer was nominated. It returns nil, false
+	// if the key owner is the current peer.
+	PickPeer(key string) (peer ProtoGetter, ok bool)
+}
+
+// Codec encodes values of a group to be transferred between peers.
+type Codec interface {
+	Encode(val Value) ([]byte, error)
+	Decode(data []byte) (Value, error)
+}
+
+// ErrNoCodec is returned when a group without a codec serves a peer.
+var ErrNoCodec = errors.New("objcache: group has no codec")
+
+type bytesCodec struct{}
+
+func (bytesCodec) Encode(val Value) ([]byte, error) {
+	if b, ok := val.([]byte); ok {
+		return b, nil
+	}
+	return nil, errors.New("objcache: value is not []byte")
+}
+
+func (bytesCodec) Decode(data []byte) (Value, error) {
+	return data, nil
+}
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(val Value) ([]byte, error) {
+	if s, ok := val.(string); ok {
+		return []byte(s), nil
+	}
+	return nil, errors.New("objcache: value is not string")
+}
+
+func (stringCodec) Decode(data []byte) (Value, error) {
+	return string(data), nil
+}
+
+type gobCodec[T any] struct{}
+
+func (gobCodec[T]) Encode(val Value) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(val)
+	return b.Bytes(), err
+}
+
+func (gobCodec[T]) Decode(data []byte) (Value, error) {
+	var val T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&val)
+	return val, err
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Encode(val Value) ([]byte, error) {
+	return json.Marshal(val)
+}
+
+func (jsonCodec[T]) Decode(data []byte) (Value, error) {
+	var val T
+	err := json.Unmarshal(data, &val)
+	return val, err
+}
+
+// NewGobCodec returns a codec of values of type T in gob format.
+func NewGobCodec[T any]() Codec {
+	return gobCodec[T]{}
+}
+
+// NewJSONCodec returns a codec of values of type T in JSON format.
+func NewJSONCodec[T any]() Codec {
+	return jsonCodec[T]{}
+}
+
+var (
+	// BytesCodec is the codec of []byte values.
+	BytesCodec Codec = bytesCodec{}
+
+	// StringCodec is the codec of string values.
+	StringCodec Codec = stringCodec{}
+)
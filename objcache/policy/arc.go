@@ -0,0 +1,176 @@
+package policy
+
+import (
+	"container/list"
+
+	"github.com/qiniu/x/objcache/lru"
+)
+
+type arcEntry[K comparable, V any] struct {
+	key   K
+	value V
+	elem  *list.Element
+	list  *list.List // t1 or t2; b1 or b2 for a ghost entry
+}
+
+// ARCCache is an Adaptive Replacement Cache (Megiddo and Modha). It keeps
+// recently used entries in T1 and frequently used ones in T2, and
+// remembers keys recently evicted from them (ghosts) in B1 and B2 to
+// adapt the target size of T1.
+type ARCCache[K comparable, V any] struct {
+	c       int // max entries
+	p       int // target size of t1
+	onEvict OnEvictFunc[K, V]
+
+	t1, t2, b1, b2 *list.List // most recently used first
+	items          map[K]*arcEntry[K, V]
+	ghosts         map[K]*arcEntry[K, V]
+}
+
+// NewARC creates an ARC cache. onEvict may be nil.
+func NewARC[K comparable, V any](maxEntries int, onEvict OnEvictFunc[K, V]) *ARCCache[K, V] {
+	c := &ARCCache[K, V]{c: maxEntries, onEvict: onEvict}
+	c.init()
+	return c
+}
+
+func (c *ARCCache[K, V]) init() {
+	c.p = 0
+	c.t1, c.t2, c.b1, c.b2 = list.New(), list.New(), list.New(), list.New()
+	c.items = make(map[K]*arcEntry[K, V])
+	c.ghosts = make(map[K]*arcEntry[K, V])
+}
+
+func (c *ARCCache[K, V]) move(e *arcEntry[K, V], to *list.List) {
+	if e.list != nil {
+		e.list.Remove(e.elem)
+	}
+	e.list, e.elem = to, to.PushFront(e)
+}
+
+// replace evicts the LRU entry of t1 or t2 into its ghost list if the
+// cache is full.
+func (c *ARCCache[K, V]) replace(inB2 bool) {
+	if len(c.items) < c.c {
+		return
+	}
+	n1 := c.t1.Len()
+	from, ghost := c.t2, c.b2
+	if n1 > 0 && (n1 > c.p || (inB2 && n1 == c.p) || c.t2.Len() == 0) {
+		from, ghost = c.t1, c.b1
+	}
+	if from.Len() == 0 {
+		return
+	}
+	e := from.Back().Value.(*arcEntry[K, V])
+	delete(c.items, e.key)
+	value := e.value
+	var zero V
+	e.value = zero
+	c.move(e, ghost)
+	c.ghosts[e.key] = e
+	if c.onEvict != nil {
+		c.onEvict(e.key, value, lru.Capacity)
+	}
+}
+
+func (c *ARCCache[K, V]) dropGhost(l *list.List) {
+	if l.Len() > 0 {
+		e := l.Remove(l.Back()).(*arcEntry[K, V])
+		delete(c.ghosts, e.key)
+	}
+}
+
+// Add adds a value to the cache.
+func (c *ARCCache[K, V]) Add(key K, value V) {
+	if e, ok := c.items[key]; ok {
+		e.value = value
+		c.move(e, c.t2)
+		return
+	}
+	if e, ok := c.ghosts[key]; ok {
+		inB2 := e.list == c.b2
+		if inB2 {
+			c.p = max(0, c.p-max(c.b1.Len()/c.b2.Len(), 1))
+		} else {
+			c.p = min(c.c, c.p+max(c.b2.Len()/c.b1.Len(), 1))
+		}
+		c.replace(inB2)
+		delete(c.ghosts, key)
+		e.value = value
+		c.move(e, c.t2)
+		c.items[key] = e
+		return
+	}
+	n1 := c.t1.Len() + c.b1.Len()
+	if n1 >= c.c {
+		if c.t1.Len() < c.c {
+			c.dropGhost(c.b1)
+			c.replace(false)
+		} else {
+			e := c.t1.Back().Value.(*arcEntry[K, V])
+			c.removeEntry(e, lru.Capacity)
+		}
+	} else if total := n1 + c.t2.Len() + c.b2.Len(); total >= c.c {
+		if total >= 2*c.c {
+			c.dropGhost(c.b2)
+		}
+		c.replace(false)
+	}
+	e := &arcEntry[K, V]{key: key, value: value}
+	c.move(e, c.t1)
+	c.items[key] = e
+}
+
+func (c *ARCCache[K, V]) removeEntry(e *arcEntry[K, V], reason lru.EvictReason) {
+	e.list.Remove(e.elem)
+	delete(c.items, e.key)
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value, reason)
+	}
+}
+
+// Get looks up a key's value from the cache.
+func (c *ARCCache[K, V]) Get(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.move(e, c.t2)
+	return e.value, true
+}
+
+// Peek looks up a key's value from the cache without updating its
+// recency or frequency.
+func (c *ARCCache[K, V]) Peek(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	return e.value, true
+}
+
+// Remove removes the provided key from the cache.
+func (c *ARCCache[K, V]) Remove(key K) {
+	if e, ok := c.items[key]; ok {
+		c.removeEntry(e, lru.Removed)
+	} else if e, ok := c.ghosts[key]; ok {
+		e.list.Remove(e.elem)
+		delete(c.ghosts, key)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *ARCCache[K, V]) Len() int {
+	return len(c.items)
+}
+
+// Clear purges all stored items from the cache.
+func (c *ARCCache[K, V]) Clear() {
+	if c.onEvict != nil {
+		for _, e := range c.items {
+			c.onEvict(e.key, e.value, lru.Removed)
+		}
+	}
+	c.init()
+}
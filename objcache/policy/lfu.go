@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"container/list"
+
+	"github.com/qiniu/x/objcache/lru"
+)
+
+type lfuEntry[K comparable, V any] struct {
+	key   K
+	value V
+	freq  int
+	elem  *list.Element
+}
+
+// LFUCache is a cache evicting the least frequently used entry. All
+// operations are O(1).
+type LFUCache[K comparable, V any] struct {
+	maxEntries int
+	onEvict    OnEvictFunc[K, V]
+	items      map[K]*lfuEntry[K, V]
+	freqs      map[int]*list.List // entries of each frequency, most recently used first
+	minFreq    int
+}
+
+// NewLFU creates a LFU cache. onEvict may be nil.
+func NewLFU[K comparable, V any](maxEntries int, onEvict OnEvictFunc[K, V]) *LFUCache[K, V] {
+	return &LFUCache[K, V]{
+		maxEntries: maxEntries,
+		onEvict:    onEvict,
+		items:      make(map[K]*lfuEntry[K, V]),
+		freqs:      make(map[int]*list.List),
+	}
+}
+
+func (c *LFUCache[K, V]) unlink(e *lfuEntry[K, V]) {
+	l := c.freqs[e.freq]
+	l.Remove(e.elem)
+	if l.Len() == 0 {
+		delete(c.freqs, e.freq)
+	}
+}
+
+func (c *LFUCache[K, V]) link(e *lfuEntry[K, V]) {
+	l, ok := c.freqs[e.freq]
+	if !ok {
+		l = list.New()
+		c.freqs[e.freq] = l
+	}
+	e.elem = l.PushFront(e)
+}
+
+func (c *LFUCache[K, V]) touch(e *lfuEntry[K, V]) {
+	c.unlink(e)
+	if e.freq == c.minFreq && c.freqs[e.freq] == nil {
+		c.minFreq++
+	}
+	e.freq++
+	c.link(e)
+}
+
+// Add adds a value to the cache.
+func (c *LFUCache[K, V]) Add(key K, value V) {
+	if e, ok := c.items[key]; ok {
+		e.value = value
+		c.touch(e)
+		return
+	}
+	if len(c.items) >= c.maxEntries {
+		c.evict()
+	}
+	e := &lfuEntry[K, V]{key: key, value: value, freq: 1}
+	c.items[key] = e
+	c.link(e)
+	c.minFreq = 1
+}
+
+func (c *LFUCache[K, V]) evict() {
+	l, ok := c.freqs[c.minFreq]
+	if !ok { // minFreq is outdated by Remove
+		c.minFreq = 0
+		for f := range c.freqs {
+			if c.minFreq == 0 || f < c.minFreq {
+				c.minFreq = f
+			}
+		}
+		if l, ok = c.freqs[c.minFreq]; !ok {
+			return
+		}
+	}
+	c.removeEntry(l.Back().Value.(*lfuEntry[K, V]), lru.Capacity)
+}
+
+func (c *LFUCache[K, V]) removeEntry(e *lfuEntry[K, V], reason lru.EvictReason) {
+	c.unlink(e)
+	delete(c.items, e.key)
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value, reason)
+	}
+}
+
+// Get looks up a key's value from the cache.
+func (c *LFUCache[K, V]) Get(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	c.touch(e)
+	return e.value, true
+}
+
+// Peek looks up a key's value from the cache without updating its
+// frequency.
+func (c *LFUCache[K, V]) Peek(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	return e.value, true
+}
+
+// Remove removes the provided key from the cache.
+func (c *LFUCache[K, V]) Remove(key K) {
+	if e, ok := c.items[key]; ok {
+		c.removeEntry(e, lru.Removed)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *LFUCache[K, V]) Len() int {
+	return len(c.items)
+}
+
+// Clear purges all stored items from the cache.
+func (c *LFUCache[K, V]) Clear() {
+	if c.onEvict != nil {
+		for _, e := range c.items {
+			c.onEvict(e.key, e.value, lru.Removed)
+		}
+	}
+	c.items = make(map[K]*lfuEntry[K, V])
+	c.freqs = make(map[int]*list.List)
+	c.minFreq = 0
+}
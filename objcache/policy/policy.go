@@ -0,0 +1,173 @@
+// Package policy implements cache eviction policies behind a common
+// interface: LRU, LFU, ARC and W-TinyLFU.
+package policy
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+
+	"github.com/qiniu/x/objcache/lru"
+)
+
+// Cache is a cache with a bounded number of entries and an eviction
+// policy. It is not safe for concurrent access.
+type Cache[K comparable, V any] interface {
+	// Add adds a value to the cache, which may evict other entries. The
+	// value of an existing key is replaced in place: it keeps the access
+	// history of the key, and isn't reported as evicted.
+	Add(key K, value V)
+
+	// Get looks up a key's value from the cache, and records the access.
+	Get(key K) (value V, ok bool)
+
+	// Peek looks up a key's value from the cache without recording the
+	// access.
+	Peek(key K) (value V, ok bool)
+
+	// Remove removes the provided key from the cache.
+	Remove(key K)
+
+	// Len returns the number of items in the cache.
+	Len() int
+
+	// Clear purges all stored items from the cache.
+	Clear()
+}
+
+// OnEvictFunc is called when an entry is purged from a cache.
+type OnEvictFunc[K comparable, V any] func(key K, value V, reason lru.EvictReason)
+
+// Kind represents an eviction policy.
+type Kind int
+
+const (
+	// LRU evicts the least recently used entry.
+	LRU Kind = iota
+	// LFU evicts the least frequently used entry, and the least recently
+	// used one among entries of the same frequency.
+	LFU
+	// ARC is the Adaptive Replacement Cache, which balances recency and
+	// frequency, and resists scans.
+	ARC
+	// TinyLFU is W-TinyLFU: a small LRU window in front of a segmented LRU
+	// main cache, which admits an entry only if a count-min sketch
+	// estimates it's more frequently used than the victim.
+	TinyLFU
+)
+
+func (k Kind) String() string {
+	switch k {
+	case LRU:
+		return "lru"
+	case LFU:
+		return "lfu"
+	case ARC:
+		return "arc"
+	case TinyLFU:
+		return "tinylfu"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// New creates a cache of the eviction policy with maxEntries. If
+// maxEntries is zero, the cache has no limit and it's an LRU cache
+// regardless of kind. onEvict may be nil.
+func New[K comparable, V any](kind Kind, maxEntries int, onEvict OnEvictFunc[K, V]) Cache[K, V] {
+	if maxEntries > 0 {
+		switch kind {
+		case LFU:
+			return NewLFU(maxEntries, onEvict)
+		case ARC:
+			return NewARC(maxEntries, onEvict)
+		case TinyLFU:
+			return NewTinyLFU(maxEntries, nil, onEvict)
+		}
+	}
+	c := lru.NewCache[K, V](maxEntries)
+	c.OnEvict = onEvict
+	return c
+}
+
+// -------------------------------------------------------------------------------------
+
+// NewHash returns a hash function of keys with a random seed. Equal keys
+// have equal hashes: pointers and channels are hashed by address, floats
+// by value (so 0 and -0 are equal), and arrays, structs and interfaces by
+// their elements.
+func NewHash[K comparable]() func(K) uint64 {
+	seed := maphash.MakeSeed()
+	return func(key K) uint64 {
+		switch k := any(key).(type) {
+		case string:
+			return maphash.String(seed, k)
+		case int:
+			return mix(uint64(k))
+		case int64:
+			return mix(uint64(k))
+		case uint64:
+			return mix(k)
+		case int32:
+			return mix(uint64(k))
+		case uint32:
+			return mix(uint64(k))
+		}
+		return hashValue(seed, reflect.ValueOf(key))
+	}
+}
+
+func hashValue(seed maphash.Seed, v reflect.Value) uint64 {
+	switch v.Kind() {
+	case reflect.String:
+		return maphash.String(seed, v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mix(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return hashFloat(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		return mix(hashFloat(real(c)) ^ hashFloat(imag(c))<<1)
+	case reflect.Bool:
+		if v.Bool() {
+			return mix(1)
+		}
+		return mix(0)
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return mix(uint64(v.Pointer()))
+	case reflect.Interface:
+		return hashValue(seed, v.Elem())
+	case reflect.Array:
+		var h uint64
+		for i := 0; i < v.Len(); i++ {
+			h = mix(h ^ hashValue(seed, v.Index(i)))
+		}
+		return h
+	case reflect.Struct:
+		var h uint64
+		for i := 0; i < v.NumField(); i++ {
+			h = mix(h ^ hashValue(seed, v.Field(i)))
+		}
+		return h
+	}
+	return 0 // a nil interface
+}
+
+func hashFloat(f float64) uint64 {
+	if f == 0 {
+		f = 0 // -0 == 0
+	}
+	return mix(math.Float64bits(f))
+}
+
+// mix is the finalizer of splitmix64.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
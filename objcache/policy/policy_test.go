@@ -0,0 +1,251 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/qiniu/x/objcache/lru"
+)
+
+var kinds = []Kind{LRU, LFU, ARC, TinyLFU}
+
+func TestBasic(t *testing.T) {
+	for _, kind := range kinds {
+		t.Run(kind.String(), func(t *testing.T) {
+			evicted := make(map[int]lru.EvictReason)
+			c := New(kind, 100, func(key int, value string, reason lru.EvictReason) {
+				if value != fmt.Sprint(key) {
+					t.Fatalf("evicted %d: %q", key, value)
+				}
+				evicted[key] = reason
+			})
+			for i := 0; i < 200; i++ {
+				c.Add(i, fmt.Sprint(i))
+				if v, ok := c.Get(i); kind != TinyLFU && (!ok || v != fmt.Sprint(i)) {
+					t.Fatalf("Get(%d): %q, %v", i, v, ok)
+				}
+			}
+			if c.Len() != 100 || len(evicted) != 100 {
+				t.Fatalf("Len: %d, evicted: %d", c.Len(), len(evicted))
+			}
+			for key, reason := range evicted {
+				if reason != lru.Capacity {
+					t.Fatalf("evicted %d: %v", key, reason)
+				}
+				if _, ok := c.Peek(key); ok {
+					t.Fatalf("Peek(%d) found an evicted key", key)
+				}
+			}
+			clear(evicted)
+			for i := 0; i < 200; i++ {
+				if _, ok := c.Peek(i); ok {
+					c.Remove(i)
+					if evicted[i] != lru.Removed {
+						t.Fatalf("Remove(%d): %v", i, evicted[i])
+					}
+					break
+				}
+			}
+			c.Add(1000, "1000")
+			clear(evicted)
+			c.Add(1000, "1000")
+			if len(evicted) != 0 {
+				t.Fatalf("replace evicted: %v", evicted)
+			}
+			c.Clear()
+			for key, reason := range evicted {
+				if reason != lru.Removed {
+					t.Fatalf("Clear %d: %v", key, reason)
+				}
+			}
+			if c.Len() != 0 || len(evicted) != 100 {
+				t.Fatalf("Clear: Len %d, evicted %d", c.Len(), len(evicted))
+			}
+			if _, ok := c.Get(1000); ok {
+				t.Fatal("Get after Clear")
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	type point struct {
+		X, Y float64
+		Name string
+	}
+	hash := NewHash[any]()
+	negZero := math.Copysign(0, -1)
+	p := &point{1, 2, "p"}
+	for _, c := range []struct{ a, b any }{
+		{0.0, negZero},
+		{float32(0), float32(negZero)},
+		{point{0, 1, "a"}, point{negZero, 1, "a"}},
+		{[2]any{"a", 1}, [2]any{"a", 1}},
+		{uint8(7), uint8(7)},
+		{p, p},
+		{nil, nil},
+	} {
+		if c.a != c.b || hash(c.a) != hash(c.b) {
+			t.Fatalf("hash(%v) != hash(%v)", c.a, c.b)
+		}
+	}
+	h := hash(p)
+	p.Name = "moved"
+	if hash(p) != h {
+		t.Fatal("hash of a pointer changes with its target")
+	}
+	if hash(&point{1, 2, "moved"}) == h {
+		t.Fatal("pointers are hashed by target")
+	}
+}
+
+func TestLFU(t *testing.T) {
+	c := NewLFU[string, int](2, nil)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Add("c", 3) // evicts b
+	if _, ok := c.Peek("b"); ok {
+		t.Fatal("b should be evicted")
+	}
+	c.Get("c")
+	c.Remove("a")
+	c.Add("d", 4)
+	c.Add("e", 5) // evicts d, the least frequently used one
+	if _, ok := c.Peek("d"); ok || c.Len() != 2 {
+		t.Fatal("d should be evicted")
+	}
+}
+
+func TestARC(t *testing.T) {
+	c := NewARC[int, int](4, nil)
+	for i := 0; i < 4; i++ {
+		c.Add(i, i)
+	}
+	c.Get(0)
+	c.Get(1)
+	for i := 10; i < 20; i++ { // a scan doesn't flush frequently used entries
+		c.Add(i, i)
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok := c.Peek(i); !ok {
+			t.Fatalf("%d should be cached", i)
+		}
+	}
+	c.Add(19, 19)
+	c.Add(18, 18) // a ghost hit
+	if _, ok := c.Peek(18); !ok || c.Len() != 4 {
+		t.Fatal("18 should be cached")
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	trace, err := ReadTrace(strings.NewReader("# key size\na 1\n\nb 2\na\n"))
+	if err != nil || strings.Join(trace, ",") != "a,b,a" {
+		t.Fatal("ReadTrace:", trace, err)
+	}
+}
+
+// -------------------------------------------------------------------------------------
+
+// zipfTrace returns a trace of n accesses to keys in Zipf distribution.
+func zipfTrace(r *rand.Rand, n int, keys uint64) []int {
+	z := rand.NewZipf(r, 1.1, 1, keys-1)
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = int(z.Uint64())
+	}
+	return trace
+}
+
+// scanTrace returns a Zipf trace interleaved with scans of keys seen only
+// once.
+func scanTrace(r *rand.Rand, n int, keys uint64) []int {
+	trace := zipfTrace(r, n, keys)
+	next := int(keys)
+	for i := 0; i+1000 < len(trace); i += 5000 {
+		for j := i; j < i+1000; j++ {
+			trace[j] = next
+			next++
+		}
+	}
+	return trace
+}
+
+// loopTrace returns accesses to keys in a loop larger than the cache.
+func loopTrace(n, keys int) []int {
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = i % keys
+	}
+	return trace
+}
+
+type trace struct {
+	name string
+	keys []int
+}
+
+func traces() []trace {
+	r := rand.New(rand.NewSource(1))
+	return []trace{
+		{"zipf", zipfTrace(r, 200000, 50000)},
+		{"scan", scanTrace(r, 200000, 50000)},
+		{"loop", loopTrace(200000, 1200)},
+	}
+}
+
+const traceCacheSize = 1000
+
+func TestHitRatio(t *testing.T) {
+	for _, tr := range traces() {
+		ratios := make(map[Kind]float64)
+		for _, kind := range kinds {
+			ratios[kind] = Simulate(New[int, struct{}](kind, traceCacheSize, nil), tr.keys)
+		}
+		t.Logf("%s: %v", tr.name, ratios)
+		if tr.name == "loop" {
+			continue
+		}
+		if ratios[TinyLFU] <= ratios[LRU] || ratios[ARC] <= ratios[LRU] {
+			t.Errorf("%s: TinyLFU and ARC should beat LRU: %v", tr.name, ratios)
+		}
+	}
+}
+
+// BenchmarkHitRatio replays traces on each policy and reports hit ratios.
+// A recorded trace (see ReadTrace) can be given by the environment
+// variable OBJCACHE_TRACE.
+func BenchmarkHitRatio(b *testing.B) {
+	if file := os.Getenv("OBJCACHE_TRACE"); file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		keys, err := ReadTrace(f)
+		f.Close()
+		if err != nil {
+			b.Fatal(err)
+		}
+		benchHitRatio(b, "recorded", keys)
+	}
+	for _, tr := range traces() {
+		benchHitRatio(b, tr.name, tr.keys)
+	}
+}
+
+func benchHitRatio[K comparable](b *testing.B, name string, keys []K) {
+	for _, kind := range kinds {
+		b.Run(name+"/"+kind.String(), func(b *testing.B) {
+			var ratio float64
+			for i := 0; i < b.N; i++ {
+				ratio = Simulate(New[K, struct{}](kind, traceCacheSize, nil), keys)
+			}
+			b.ReportMetric(100*ratio, "hit%")
+		})
+	}
+}
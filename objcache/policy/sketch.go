@@ -0,0 +1,64 @@
+package policy
+
+const (
+	sketchDepth   = 4
+	sketchMaxFreq = 15
+)
+
+// cmSketch is a count-min sketch of 4-bit-like saturating counters,
+// estimating access frequencies of keys. Counters are halved every
+// sampleSize increments, so the estimates reflect recent history.
+type cmSketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint32
+	additions  int
+	sampleSize int
+}
+
+func newCMSketch(maxEntries int) *cmSketch {
+	width := 16
+	for width < maxEntries {
+		width <<= 1
+	}
+	s := &cmSketch{mask: uint32(width - 1), sampleSize: 10 * max(maxEntries, 1)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *cmSketch) index(h uint64, i int) uint32 {
+	lo, hi := uint32(h), uint32(h>>32)
+	return (lo + uint32(i)*hi + uint32(i*i)) & s.mask
+}
+
+// increment records an access of the key hashed to h.
+func (s *cmSketch) increment(h uint64) {
+	for i := range s.rows {
+		if c := &s.rows[i][s.index(h, i)]; *c < sketchMaxFreq {
+			*c++
+		}
+	}
+	if s.additions++; s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+// estimate returns the estimated access frequency of the key hashed to h.
+func (s *cmSketch) estimate(h uint64) uint8 {
+	ret := uint8(sketchMaxFreq)
+	for i := range s.rows {
+		ret = min(ret, s.rows[i][s.index(h, i)])
+	}
+	return ret
+}
+
+// reset halves all counters.
+func (s *cmSketch) reset() {
+	for _, row := range s.rows {
+		for j := range row {
+			row[j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,176 @@
+package policy
+
+import (
+	"container/list"
+
+	"github.com/qiniu/x/objcache/lru"
+)
+
+const (
+	segWindow = iota
+	segProbation
+	segProtected
+)
+
+type tinyEntry[K comparable, V any] struct {
+	key   K
+	value V
+	hash  uint64
+	elem  *list.Element
+	seg   int
+}
+
+// TinyLFUCache is a W-TinyLFU cache. New entries go to a small LRU window
+// (1% of the capacity). An entry evicted from the window is admitted to
+// the main cache, a segmented LRU of probation and protected (80% of the
+// main cache) segments, only if a count-min sketch estimates it's used
+// more frequently than the main cache's victim.
+type TinyLFUCache[K comparable, V any] struct {
+	maxEntries   int
+	maxWindow    int
+	maxProtected int
+	hash         func(K) uint64
+	onEvict      OnEvictFunc[K, V]
+	sketch       *cmSketch
+
+	segs  [3]*list.List // most recently used first
+	items map[K]*tinyEntry[K, V]
+}
+
+// NewTinyLFU creates a W-TinyLFU cache. If hash is nil, NewHash is used.
+// onEvict may be nil.
+func NewTinyLFU[K comparable, V any](maxEntries int, hash func(K) uint64, onEvict OnEvictFunc[K, V]) *TinyLFUCache[K, V] {
+	if hash == nil {
+		hash = NewHash[K]()
+	}
+	maxWindow := max(maxEntries/100, 1)
+	c := &TinyLFUCache[K, V]{
+		maxEntries:   maxEntries,
+		maxWindow:    maxWindow,
+		maxProtected: (maxEntries - maxWindow) * 8 / 10,
+		hash:         hash,
+		onEvict:      onEvict,
+		sketch:       newCMSketch(maxEntries),
+	}
+	c.init()
+	return c
+}
+
+func (c *TinyLFUCache[K, V]) init() {
+	for i := range c.segs {
+		c.segs[i] = list.New()
+	}
+	c.items = make(map[K]*tinyEntry[K, V])
+}
+
+func (c *TinyLFUCache[K, V]) move(e *tinyEntry[K, V], seg int) {
+	c.segs[e.seg].Remove(e.elem)
+	e.seg, e.elem = seg, c.segs[seg].PushFront(e)
+}
+
+func (c *TinyLFUCache[K, V]) touch(e *tinyEntry[K, V]) {
+	switch e.seg {
+	case segProbation:
+		c.move(e, segProtected)
+		if protected := c.segs[segProtected]; protected.Len() > c.maxProtected {
+			c.move(protected.Back().Value.(*tinyEntry[K, V]), segProbation)
+		}
+	default:
+		c.segs[e.seg].MoveToFront(e.elem)
+	}
+}
+
+// Add adds a value to the cache.
+func (c *TinyLFUCache[K, V]) Add(key K, value V) {
+	if e, ok := c.items[key]; ok {
+		c.sketch.increment(e.hash)
+		e.value = value
+		c.touch(e)
+		return
+	}
+	h := c.hash(key)
+	c.sketch.increment(h)
+	e := &tinyEntry[K, V]{key: key, value: value, hash: h, seg: segWindow}
+	e.elem = c.segs[segWindow].PushFront(e)
+	c.items[key] = e
+
+	window := c.segs[segWindow]
+	if window.Len() <= c.maxWindow {
+		return
+	}
+	candidate := window.Back().Value.(*tinyEntry[K, V])
+	if c.Len() <= c.maxEntries {
+		c.move(candidate, segProbation)
+		return
+	}
+	victim := c.victim()
+	if victim != nil && c.sketch.estimate(candidate.hash) > c.sketch.estimate(victim.hash) {
+		c.move(candidate, segProbation)
+		c.removeEntry(victim, lru.Capacity)
+	} else {
+		c.removeEntry(candidate, lru.Capacity)
+	}
+}
+
+// victim returns the entry of the main cache to be evicted.
+func (c *TinyLFUCache[K, V]) victim() *tinyEntry[K, V] {
+	for _, seg := range [...]int{segProbation, segProtected} {
+		if l := c.segs[seg]; l.Len() > 0 {
+			return l.Back().Value.(*tinyEntry[K, V])
+		}
+	}
+	return nil
+}
+
+func (c *TinyLFUCache[K, V]) removeEntry(e *tinyEntry[K, V], reason lru.EvictReason) {
+	c.segs[e.seg].Remove(e.elem)
+	delete(c.items, e.key)
+	if c.onEvict != nil {
+		c.onEvict(e.key, e.value, reason)
+	}
+}
+
+// Get looks up a key's value from the cache.
+func (c *TinyLFUCache[K, V]) Get(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if !ok {
+		c.sketch.increment(c.hash(key))
+		return
+	}
+	c.sketch.increment(e.hash)
+	c.touch(e)
+	return e.value, true
+}
+
+// Peek looks up a key's value from the cache without recording the
+// access.
+func (c *TinyLFUCache[K, V]) Peek(key K) (value V, ok bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return
+	}
+	return e.value, true
+}
+
+// Remove removes the provided key from the cache.
+func (c *TinyLFUCache[K, V]) Remove(key K) {
+	if e, ok := c.items[key]; ok {
+		c.removeEntry(e, lru.Removed)
+	}
+}
+
+// Len returns the number of items in the cache.
+func (c *TinyLFUCache[K, V]) Len() int {
+	return len(c.items)
+}
+
+// Clear purges all stored items from the cache. The frequency history is
+// kept.
+func (c *TinyLFUCache[K, V]) Clear() {
+	if c.onEvict != nil {
+		for _, e := range c.items {
+			c.onEvict(e.key, e.value, lru.Removed)
+		}
+	}
+	c.init()
+}
@@ -0,0 +1,40 @@
+package policy
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Simulate replays a trace of key accesses on cache c: each key is looked
+// up, and added if missing. It returns the hit ratio.
+func Simulate[K comparable](c Cache[K, struct{}], trace []K) float64 {
+	if len(trace) == 0 {
+		return 0
+	}
+	hits := 0
+	for _, key := range trace {
+		if _, ok := c.Get(key); ok {
+			hits++
+		} else {
+			c.Add(key, struct{}{})
+		}
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+// ReadTrace reads a recorded trace of key accesses, one key per line. The
+// key is the first field of a line, so that traces with extra columns
+// (timestamps, sizes, etc.) can be used. Blank lines and lines starting
+// with '#' are skipped.
+func ReadTrace(r io.Reader) (trace []string, err error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		trace = append(trace, fields[0])
+	}
+	return trace, s.Err()
+}
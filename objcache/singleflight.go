@@ -0,0 +1,107 @@
+package objcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// errGoexit is the error of a load that called runtime.Goexit.
+var errGoexit = errors.New("objcache: load called runtime.Goexit")
+
+// panicError is a panic recovered from a load, with the stack trace of
+// the loading goroutine.
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (p *panicError) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// call is an in-flight or completed load.
+type call struct {
+	done  chan struct{}
+	val   Value
+	err   error
+	panic *panicError // non-nil if the load panicked
+}
+
+// wait waits for the load to complete. If ctx is a context.Context, the
+// wait is canceled when ctx is done, but the load goes on for others.
+func (c *call) wait(ctx Context) (Value, error) {
+	if cctx, ok := ctx.(context.Context); ok {
+		select {
+		case <-c.done:
+		case <-cctx.Done():
+			return nil, cctx.Err()
+		}
+	} else {
+		<-c.done
+	}
+	if c.panic != nil {
+		panic(c.panic)
+	}
+	return c.val, c.err
+}
+
+// flightGroup deduplicates concurrent loads of the same key.
+type flightGroup struct {
+	mu sync.Mutex
+	m  map[Key]*call
+}
+
+// do executes fn for key, making sure that only one execution is in-flight
+// for a given key at a time. Callers of a duplicate key wait for the
+// original to complete and receive the same results. shared reports
+// whether the results come from another call.
+//
+// If ctx is a cancelable context.Context, fn runs in its own goroutine
+// with a context that is never canceled, so canceling any caller doesn't
+// abort the load.
+//
+// If fn panics, the key is released and the panic is propagated to every
+// caller waiting for the result, with the stack trace of fn.
+func (g *flightGroup) do(ctx Context, key Key, fn func(ctx Context) (Value, error)) (val Value, err error, shared bool) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[Key]*call)
+	}
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		val, err = c.wait(ctx)
+		return val, err, true
+	}
+	c := &call{done: make(chan struct{})}
+	g.m[key] = c
+	g.mu.Unlock()
+
+	load := func(ctx Context) {
+		normal := false
+		defer func() {
+			if !normal {
+				if r := recover(); r != nil {
+					c.panic = &panicError{value: r, stack: debug.Stack()}
+				} else {
+					c.err = errGoexit
+				}
+			}
+			g.mu.Lock()
+			delete(g.m, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+		c.val, c.err = fn(ctx)
+		normal = true
+	}
+	if cctx, ok := ctx.(context.Context); ok && cctx.Done() != nil {
+		go load(context.WithoutCancel(cctx))
+	} else {
+		load(ctx)
+	}
+	val, err = c.wait(ctx)
+	return val, err, false
+}
@@ -0,0 +1,436 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package archive implements opening members of zip, tar and tar.gz
+// archives. A member is addressed by the archive URI and the member names
+// separated by '#', where the archive URI is any stream URI:
+//
+//	a.zip#dir/b.txt
+//	http://example.com/a.tar.gz#b.txt
+//	outer.zip#inner.tar#b.txt
+//
+// A '#' separates member names only after a name with an archive extension,
+// so "a.zip#b#1.txt" opens the member "b#1.txt" of a.zip.
+//
+// Indexes of opened archives are cached, so repeated member opens don't
+// rescan the archive. A cached archive on the local file system is
+// reloaded when the file changes; others are kept until evicted or Purge.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/qiniu/x/stream"
+)
+
+// -------------------------------------------------------------------------------------
+
+// Format represents an archive format.
+type Format int
+
+const (
+	Zip Format = iota
+	Tar
+	Tgz // gzip-compressed tar
+)
+
+func (f Format) String() string {
+	switch f {
+	case Zip:
+		return "zip"
+	case Tar:
+		return "tar"
+	case Tgz:
+		return "tgz"
+	}
+	return "unknown"
+}
+
+// FormatOf returns the archive format of a file by its extension.
+func FormatOf(name string) (f Format, ok bool) {
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip, true
+	case strings.HasSuffix(name, ".tar"):
+		return Tar, true
+	case strings.HasSuffix(name, ".tgz"), strings.HasSuffix(name, ".tar.gz"):
+		return Tgz, true
+	}
+	return
+}
+
+// -------------------------------------------------------------------------------------
+
+type member struct {
+	name    string
+	size    int64
+	modTime time.Time
+	zf      *zip.File // nil for tar members
+	offset  int64     // offset of a tar member
+}
+
+func (m *member) metadata() *stream.Metadata {
+	return &stream.Metadata{
+		Size:        m.size,
+		ModTime:     m.modTime,
+		ContentType: mime.TypeByExtension(path.Ext(m.name)),
+	}
+}
+
+// stamp identifies a version of an archive file.
+type stamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func (p stamp) equal(q stamp) bool {
+	return p.size == q.size && p.modTime.Equal(q.modTime)
+}
+
+// archive represents an indexed archive. Its members are read from r.
+type archive struct {
+	r     io.ReaderAt
+	files map[string]*member
+	stamp stamp
+	close func() // releases r
+
+	refs    int // guarded by mutex
+	evicted bool
+}
+
+func newArchive(r io.ReaderAt, size int64, format Format, close func()) (a *archive, err error) {
+	a = &archive{r: r, files: make(map[string]*member), close: close}
+	if format == Zip {
+		err = a.indexZip(size)
+	} else {
+		err = a.indexTar(size)
+	}
+	if err != nil {
+		close()
+		return nil, err
+	}
+	return
+}
+
+func (a *archive) indexZip(size int64) error {
+	zr, err := zip.NewReader(a.r, size)
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		if _, ok := a.files[zf.Name]; !ok {
+			a.files[zf.Name] = &member{
+				name: zf.Name, size: int64(zf.UncompressedSize64), modTime: zf.Modified, zf: zf,
+			}
+		}
+	}
+	return nil
+}
+
+func (a *archive) indexTar(size int64) error {
+	sr := io.NewSectionReader(a.r, 0, size)
+	tr := tar.NewReader(sr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		offset, _ := sr.Seek(0, io.SeekCurrent) // tar.Reader stops at the start of data
+		a.files[hdr.Name] = &member{
+			name: hdr.Name, size: hdr.Size, modTime: hdr.ModTime, offset: offset,
+		}
+	}
+}
+
+func (a *archive) member(name string) (*member, error) {
+	if m, ok := a.files[name]; ok {
+		return m, nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+func (a *archive) open(m *member) (io.ReadCloser, error) {
+	if m.zf != nil {
+		return m.zf.Open()
+	}
+	return io.NopCloser(io.NewSectionReader(a.r, m.offset, m.size)), nil
+}
+
+// section returns the position of an uncompressed member in the archive.
+func (a *archive) section(m *member) (offset int64, ok bool) {
+	if m.zf == nil {
+		return m.offset, true
+	}
+	if m.zf.Method != zip.Store {
+		return
+	}
+	offset, err := m.zf.DataOffset()
+	return offset, err == nil
+}
+
+// -------------------------------------------------------------------------------------
+
+// loadRoot opens and indexes the archive identified by uri.
+func loadRoot(ctx context.Context, format Format, uri string) (a *archive, err error) {
+	rc, err := stream.OpenRaw(ctx, uri)
+	if err != nil {
+		return
+	}
+	f, ok := fileOf(rc)
+	if !ok || format == Tgz {
+		f, err = spool(rc, format == Tgz)
+		rc.Close()
+		if err != nil {
+			return
+		}
+		rc = tempFile{f}
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		rc.Close()
+		return
+	}
+	if format == Tgz {
+		format = Tar // f is decompressed
+	}
+	return newArchive(f, fi.Size(), format, closer(rc))
+}
+
+// loadNested opens and indexes the archive member m of the archive a.
+func (a *archive) loadNested(m *member, format Format) (*archive, error) {
+	if offset, ok := a.section(m); ok && format != Tgz {
+		acquire(a) // the nested archive reads from a
+		return newArchive(io.NewSectionReader(a.r, offset, m.size), m.size, format, a.release)
+	}
+	rc, err := a.open(m)
+	if err != nil {
+		return nil, err
+	}
+	f, err := spool(rc, format == Tgz)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		tempFile{f}.Close()
+		return nil, err
+	}
+	if format == Tgz {
+		format = Tar // f is decompressed
+	}
+	return newArchive(f, fi.Size(), format, closer(tempFile{f}))
+}
+
+// fileOf returns the underlying file of a stream, if any.
+func fileOf(r io.Reader) (*os.File, bool) {
+	for {
+		switch v := r.(type) {
+		case *os.File:
+			return v, true
+		case interface{ Unwrap() io.ReadCloser }:
+			r = v.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+}
+
+type tempFile struct {
+	*os.File
+}
+
+func (p tempFile) Close() error {
+	err := p.File.Close()
+	os.Remove(p.Name())
+	return err
+}
+
+// spool copies r into a temporary file, which should be removed by closing
+// it as a tempFile. If gunzip is true, r is decompressed.
+func spool(r io.Reader, gunzip bool) (*os.File, error) {
+	if gunzip {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	f, err := os.CreateTemp("", "stream-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		tempFile{f}.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func closer(c io.Closer) func() {
+	return func() { c.Close() }
+}
+
+// -------------------------------------------------------------------------------------
+
+// parse parses "uri#name1#name2...". A '#' starts the name of a member
+// of a nested archive only if the name before it has an archive extension
+// (see FormatOf); otherwise it's part of the member name.
+func parse(url string) (uri string, names []string, err error) {
+	uri, path, ok := strings.Cut(url, "#")
+	if !ok || uri == "" || path == "" {
+		return "", nil, fs.ErrInvalid
+	}
+	parts := strings.Split(path, "#")
+	name := parts[0]
+	for _, part := range parts[1:] {
+		if _, nested := FormatOf(name); nested {
+			if name == "" || part == "" {
+				return "", nil, fs.ErrInvalid
+			}
+			names = append(names, name)
+			name = part
+		} else {
+			name += "#" + part
+		}
+	}
+	if name == "" {
+		return "", nil, fs.ErrInvalid
+	}
+	return uri, append(names, name), nil
+}
+
+// find locates the archive and the member addressed by url. The returned
+// archive must be released.
+func find(ctx context.Context, format Format, url string) (a *archive, m *member, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	uri, names, err := parse(url)
+	if err != nil {
+		return
+	}
+	var st stamp
+	if isLocal(uri) {
+		fi, err := os.Stat(uri)
+		if err != nil {
+			return nil, nil, err
+		}
+		st = stamp{fi.Size(), fi.ModTime()}
+	}
+	key := format.String() + ":" + uri
+	a, err = openArchive(key, st, func() (*archive, error) {
+		return loadRoot(ctx, format, uri)
+	})
+	if err != nil {
+		return
+	}
+	last := len(names) - 1
+	for _, name := range names[:last] {
+		if m, err = a.member(name); err != nil {
+			a.release()
+			return nil, nil, err
+		}
+		parent, nested := a, format
+		if f, ok := FormatOf(name); ok {
+			nested = f
+		}
+		key += "#" + name
+		a, err = openArchive(key, st, func() (*archive, error) {
+			return parent.loadNested(m, nested)
+		})
+		parent.release()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if m, err = a.member(names[last]); err != nil {
+		a.release()
+		return nil, nil, err
+	}
+	return
+}
+
+// isLocal reports whether uri is a local file path, i.e. it has no scheme.
+func isLocal(uri string) bool {
+	pos := strings.IndexAny(uri, ":/")
+	return pos <= 0 || uri[pos] != ':'
+}
+
+// Open opens a member of an archive in the format. url is the archive URI
+// followed by one or more member names, each prefixed by '#'; members but
+// the last are nested archives, whose formats are guessed by extensions.
+func Open(ctx context.Context, format Format, url string) (io.ReadCloser, error) {
+	a, m, err := find(ctx, format, url)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := a.open(m)
+	if err != nil {
+		a.release()
+		return nil, err
+	}
+	return &readCloser{rc, a, m}, nil
+}
+
+// Stat returns metadata of a member of an archive. See Open.
+func Stat(ctx context.Context, format Format, url string) (*stream.Metadata, error) {
+	a, m, err := find(ctx, format, url)
+	if err != nil {
+		return nil, err
+	}
+	a.release()
+	return m.metadata(), nil
+}
+
+type readCloser struct {
+	io.ReadCloser
+	a *archive
+	m *member
+}
+
+// Metadata returns metadata of the archive member.
+func (p *readCloser) Metadata() *stream.Metadata {
+	return p.m.metadata()
+}
+
+func (p *readCloser) Close() error {
+	err := p.ReadCloser.Close()
+	if p.a != nil {
+		p.a.release()
+		p.a = nil
+	}
+	return err
+}
+
+// -------------------------------------------------------------------------------------
@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qiniu/x/mockhttp"
+	streamhttp "github.com/qiniu/x/stream/http"
+	_ "github.com/qiniu/x/stream/http/nocache"
+)
+
+func makeZip(files map[string]string, method uint16) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, _ := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		io.WriteString(w, data)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func makeTar(files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))})
+		io.WriteString(tw, data)
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func gzipOf(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func readMember(t *testing.T, format Format, url string) string {
+	t.Helper()
+	f, err := Open(context.Background(), format, url)
+	if err != nil {
+		t.Fatal("Open:", url, err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal("ReadAll:", url, err)
+	}
+	return string(b)
+}
+
+func TestOpen(t *testing.T) {
+	defer Purge()
+	dir := t.TempDir()
+	inner := makeTar(map[string]string{"c.txt": "in tar"})
+	files := map[string]string{
+		"a.zip": string(makeZip(map[string]string{
+			"b.txt":      "hello",
+			"#b#.txt":    "sharp",
+			"stored.zip": string(makeZip(map[string]string{"c.txt": "stored", "c#1.txt": "stored#1"}, zip.Store)),
+			"packed.zip": string(makeZip(map[string]string{"c.txt": "packed"}, zip.Deflate)),
+			"inner.tgz":  string(gzipOf(inner)),
+		}, zip.Deflate)),
+		"a.tar": string(makeTar(map[string]string{"b.txt": "tar", "inner.tar": string(inner)})),
+		"a.tgz": string(gzipOf(makeTar(map[string]string{"b.txt": "tgz"}))),
+	}
+	for name, data := range files {
+		os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+	}
+	cases := []struct {
+		format Format
+		url    string
+		ret    string
+	}{
+		{Zip, "a.zip#b.txt", "hello"},
+		{Zip, "a.zip##b#.txt", "sharp"},
+		{Zip, "a.zip#stored.zip#c.txt", "stored"},
+		{Zip, "a.zip#stored.zip#c#1.txt", "stored#1"},
+		{Zip, "a.zip#packed.zip#c.txt", "packed"},
+		{Zip, "a.zip#inner.tgz#c.txt", "in tar"},
+		{Tar, "a.tar#b.txt", "tar"},
+		{Tar, "a.tar#inner.tar#c.txt", "in tar"},
+		{Tgz, "a.tgz#b.txt", "tgz"},
+	}
+	for _, c := range cases {
+		if ret := readMember(t, c.format, filepath.Join(dir, c.url)); ret != c.ret {
+			t.Fatal(c.url, ret)
+		}
+	}
+	if n := cache.Len(); n != 7 {
+		t.Fatal("cache.Len:", n)
+	}
+	if ret := readMember(t, Zip, filepath.Join(dir, "a.zip#b.txt")); ret != "hello" || cache.Len() != 7 {
+		t.Fatal("reopen:", ret, cache.Len())
+	}
+
+	meta, err := Stat(context.Background(), Tar, filepath.Join(dir, "a.tar#b.txt"))
+	if err != nil || meta.Size != 3 {
+		t.Fatal("Stat:", meta, err)
+	}
+	for _, url := range []string{"a.zip#none", "a.zip#b.txt#c.txt", "a.zip#"} {
+		if _, err := Open(context.Background(), Zip, filepath.Join(dir, url)); err == nil {
+			t.Fatal("Open:", url, "no error")
+		}
+	}
+	if _, err := Open(context.Background(), Zip, filepath.Join(dir, "a.zip#none")); err != fs.ErrNotExist {
+		t.Fatal("Open none:", err)
+	}
+
+	// a changed archive is reloaded
+	os.WriteFile(filepath.Join(dir, "a.tar"), makeTar(map[string]string{"b.txt": "changed"}), 0644)
+	if ret := readMember(t, Tar, filepath.Join(dir, "a.tar#b.txt")); ret != "changed" {
+		t.Fatal("changed:", ret)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	defer SetCacheSize(DefaultCacheSize)
+	dir := t.TempDir()
+	for _, name := range []string{"a.zip", "b.zip"} {
+		os.WriteFile(filepath.Join(dir, name), makeZip(map[string]string{"c.txt": name}, zip.Deflate), 0644)
+	}
+	SetCacheSize(1)
+	f, err := Open(context.Background(), Zip, filepath.Join(dir, "a.zip#c.txt"))
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer f.Close()
+	if ret := readMember(t, Zip, filepath.Join(dir, "b.zip#c.txt")); ret != "b.zip" || cache.Len() != 1 {
+		t.Fatal("Open b.zip:", ret, cache.Len())
+	}
+	// a.zip is evicted, but still readable until closed
+	if b, err := io.ReadAll(f); err != nil || string(b) != "a.zip" {
+		t.Fatal("read evicted:", string(b), err)
+	}
+}
+
+func TestRemote(t *testing.T) {
+	defer Purge()
+	data := makeZip(map[string]string{"b.txt": "remote"}, zip.Deflate)
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("example.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	old := streamhttp.Client
+	streamhttp.Client = &http.Client{Transport: tr}
+	defer func() { streamhttp.Client = old }()
+
+	for i := 0; i < 2; i++ {
+		if ret := readMember(t, Zip, "http://example.com/a.zip#b.txt"); ret != "remote" {
+			t.Fatal("remote:", ret)
+		}
+	}
+	if n := len(tr.Calls()); n != 1 {
+		t.Fatal("remote archive is downloaded", n, "times")
+	}
+}
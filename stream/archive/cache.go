@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package archive
+
+import (
+	"sync"
+
+	"github.com/qiniu/x/objcache/lru"
+)
+
+// DefaultCacheSize is the default maximum number of cached archives.
+const DefaultCacheSize = 16
+
+var (
+	mutex   sync.Mutex
+	cache   = newCache()
+	zombies []*archive // evicted archives to close after unlocking mutex
+)
+
+func newCache() *lru.Cache[string, *archive] {
+	c := lru.NewCache[string, *archive](DefaultCacheSize)
+	c.OnEvicted = func(key string, a *archive) {
+		a.evicted = true
+		if a.refs == 0 {
+			zombies = append(zombies, a)
+		}
+	}
+	return c
+}
+
+func unlock() {
+	z := zombies
+	zombies = nil
+	mutex.Unlock()
+	for _, a := range z {
+		a.close()
+	}
+}
+
+// SetCacheSize sets the maximum number of cached archives. Zero means no
+// limit. Archives in use are closed when they are no longer used.
+func SetCacheSize(n int) {
+	mutex.Lock()
+	defer unlock()
+	cache.Resize(n, 0)
+}
+
+// Purge removes all cached archives.
+func Purge() {
+	mutex.Lock()
+	defer unlock()
+	cache.Clear()
+}
+
+// openArchive returns the cached archive of key if its stamp is st, or
+// loads it by calling load. The returned archive must be released.
+func openArchive(key string, st stamp, load func() (*archive, error)) (*archive, error) {
+	if a := lookup(key, st); a != nil {
+		return a, nil
+	}
+	a, err := load()
+	if err != nil {
+		return nil, err
+	}
+	a.stamp = st
+	return share(key, a), nil
+}
+
+func lookup(key string, st stamp) *archive {
+	mutex.Lock()
+	defer unlock()
+	if a, ok := cache.Get(key); ok {
+		if a.stamp.equal(st) {
+			a.refs++
+			return a
+		}
+		cache.Remove(key) // outdated
+	}
+	return nil
+}
+
+// share adds a to the cache. If the same archive was loaded concurrently,
+// a is closed and the cached one is returned instead.
+func share(key string, a *archive) *archive {
+	mutex.Lock()
+	defer unlock()
+	if old, ok := cache.Get(key); ok {
+		if old.stamp.equal(a.stamp) {
+			old.refs++
+			zombies = append(zombies, a)
+			return old
+		}
+		cache.Remove(key)
+	}
+	a.refs = 1
+	cache.Add(key, a)
+	return a
+}
+
+func acquire(a *archive) {
+	mutex.Lock()
+	a.refs++
+	mutex.Unlock()
+}
+
+func (a *archive) release() {
+	mutex.Lock()
+	defer unlock()
+	if a.refs--; a.refs == 0 && a.evicted {
+		zombies = append(zombies, a)
+	}
+}
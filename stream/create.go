@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// -------------------------------------------------------------------------------------
+
+// CreateFunc defines the function type for creating (or overwriting) a
+// resource by URL. The resource is written when the returned writer is
+// closed successfully.
+type CreateFunc = func(ctx context.Context, url string) (io.WriteCloser, error)
+
+var (
+	creators = map[string]CreateFunc{}
+)
+
+// RegisterCreate registers a scheme with a create function.
+func RegisterCreate(scheme string, create CreateFunc) {
+	creators[scheme] = create
+}
+
+// Create creates a resource identified by the given URI for writing. It
+// supports different schemes by utilizing registered create functions. If
+// the URI has no scheme, it is treated as a file path (see CreateFile).
+//
+// Canceling ctx before Close discards what has been written, if the scheme
+// supports it.
+func Create(ctx context.Context, uri string) (io.WriteCloser, error) {
+	scheme := schemeOf(uri)
+	if scheme == "" {
+		return CreateFile(ctx, uri)
+	}
+	if create, ok := creators[scheme]; ok {
+		return create(ctx, uri)
+	}
+	return nil, &fs.PathError{Op: "stream.Create", Err: ErrUnknownScheme, Path: uri}
+}
+
+// -------------------------------------------------------------------------------------
+
+// CreateFile creates a file for writing. The content is written into a
+// temporary file in the same directory, which is renamed to name on Close,
+// so readers never see a partially written file. If ctx is canceled before
+// Close, or writing fails, the temporary file is removed and name is left
+// untouched.
+func CreateFile(ctx context.Context, name string) (io.WriteCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	mode := fs.FileMode(0644)
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*~")
+	if err != nil {
+		return nil, err
+	}
+	if err = f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &fileWriter{f, ctx, name, nil}, nil
+}
+
+type fileWriter struct {
+	f    *os.File
+	ctx  context.Context
+	name string
+	err  error // the first write error
+}
+
+func (p *fileWriter) Write(b []byte) (n int, err error) {
+	n, err = p.f.Write(b)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return
+}
+
+func (p *fileWriter) Close() error {
+	tmp := p.f.Name()
+	err := p.err
+	if err == nil {
+		err = p.ctx.Err()
+	}
+	if err == nil {
+		err = p.f.Sync()
+	}
+	if cerr := p.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, p.name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// filePath returns the path of a "file:" URI.
+func filePath(url string) string {
+	file := strings.TrimPrefix(url, "file:")
+	if strings.HasPrefix(file, "//") {
+		file = file[2:]
+		if pos := strings.IndexByte(file, '/'); pos >= 0 {
+			file = file[pos:] // file://host/path: host is ignored
+		}
+	}
+	return file
+}
+
+func init() {
+	// file:///path/to/file
+	RegisterContext("file", func(ctx context.Context, url string) (io.ReadCloser, error) {
+		return OpenRaw(ctx, filePath(url))
+	})
+	RegisterStat("file", func(ctx context.Context, url string) (*Metadata, error) {
+		return Stat(ctx, filePath(url))
+	})
+	RegisterCreate("file", func(ctx context.Context, url string) (io.WriteCloser, error) {
+		return CreateFile(ctx, filePath(url))
+	})
+}
+
+// -------------------------------------------------------------------------------------
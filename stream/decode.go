@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	// ErrUnknownCodec is returned when an unknown codec is specified.
+	ErrUnknownCodec = errors.New("unknown codec")
+)
+
+// -------------------------------------------------------------------------------------
+
+// Codec represents a decoding filter, eg. a decompressor.
+type Codec struct {
+	Name      string   // name used in "?decode=<name>"
+	Exts      []string // file extensions, eg. ".gz"
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	codecs    = map[string]*Codec{}
+	codecExts = map[string]*Codec{}
+)
+
+// RegisterCodec registers a codec. It replaces the codec of the same name
+// or extension, if any. Builtin codecs are gzip (.gz), bzip2 (.bz2) and zlib
+// (.zz); others, such as zstd (.zst), can be registered by their
+// implementations.
+func RegisterCodec(c *Codec) {
+	codecs[c.Name] = c
+	for _, ext := range c.Exts {
+		codecExts[ext] = c
+	}
+}
+
+func init() {
+	RegisterCodec(&Codec{Name: "gzip", Exts: []string{".gz"}, NewReader: func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	}})
+	RegisterCodec(&Codec{Name: "bzip2", Exts: []string{".bz2"}, NewReader: func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}})
+	RegisterCodec(&Codec{Name: "zlib", Exts: []string{".zz"}, NewReader: zlib.NewReader})
+}
+
+// -------------------------------------------------------------------------------------
+
+// Decode returns a stream that decodes rc by the named codecs in order.
+// Closing it closes rc. Its metadata is inherited from rc, but the size is
+// unknown.
+func Decode(rc io.ReadCloser, names ...string) (io.ReadCloser, error) {
+	if len(names) == 0 {
+		return rc, nil
+	}
+	meta := *MetadataOf(rc)
+	meta.Size = -1
+	ret := &decodeReader{closers: []io.Closer{rc}, meta: &meta}
+	r := io.Reader(rc)
+	for _, name := range names {
+		c, ok := codecs[name]
+		if !ok {
+			ret.Close()
+			return nil, &fs.PathError{Op: "stream.Decode", Err: ErrUnknownCodec, Path: name}
+		}
+		dec, err := c.NewReader(r)
+		if err != nil {
+			ret.Close()
+			return nil, err
+		}
+		ret.closers = append(ret.closers, dec)
+		r = dec
+	}
+	ret.Reader = r
+	return ret, nil
+}
+
+type decodeReader struct {
+	io.Reader
+	closers []io.Closer
+	meta    *Metadata
+}
+
+func (p *decodeReader) Metadata() *Metadata {
+	return p.meta
+}
+
+func (p *decodeReader) Close() (err error) {
+	for i := len(p.closers) - 1; i >= 0; i-- {
+		if e := p.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+var autoDecode atomic.Bool
+
+// SetAutoDecode sets whether Open and OpenContext decode resources like
+// OpenDecoded. It's off by default, so that a URI with a codec extension
+// (eg. "a.json.gz") opens the raw content.
+func SetAutoDecode(on bool) {
+	autoDecode.Store(on)
+}
+
+// OpenDecoded opens a resource like OpenContext, and then decodes it by the
+// codecs specified by a "decode=<name>[,<name>...]" parameter at the end of
+// the URI (eg. "file.log?decode=gzip"), which is removed before opening.
+// Without the parameter, the codec is chosen by the extension of the URI
+// (eg. "http://host/a.json.gz"); "decode=none" disables decoding.
+func OpenDecoded(ctx context.Context, uri string) (io.ReadCloser, error) {
+	uri, names := decodersOf(uri)
+	rc, err := OpenRaw(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := Decode(rc, names...)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 1 {
+		if c := codecs[names[0]]; c != nil {
+			if ext := path.Ext(nameOf(uri)); hasExt(c, ext) {
+				meta := ret.(*decodeReader).meta
+				meta.ContentType = mime.TypeByExtension(path.Ext(strings.TrimSuffix(nameOf(uri), ext)))
+			}
+		}
+	}
+	return ret, nil
+}
+
+// decodersOf returns the URI to open and the names of codecs to apply.
+func decodersOf(uri string) (string, []string) {
+	if pos := strings.LastIndex(uri, "decode="); pos > 0 && strings.IndexByte(uri[pos:], '&') < 0 {
+		if sep := uri[pos-1]; sep == '?' || sep == '&' {
+			v := uri[pos+len("decode="):]
+			uri = uri[:pos-1]
+			if v == "none" {
+				return uri, nil
+			}
+			return uri, strings.Split(v, ",")
+		}
+	}
+	if c, ok := codecExts[path.Ext(nameOf(uri))]; ok {
+		return uri, []string{c.Name}
+	}
+	return uri, nil
+}
+
+// nameOf returns the name part of the URI without query and fragment. For
+// an archive member (eg. "zip:a.zip#b.txt"), it's the member name.
+func nameOf(uri string) string {
+	if schemeOf(uri) != "" {
+		if pos := strings.LastIndexByte(uri, '#'); pos >= 0 {
+			uri = uri[pos+1:]
+		}
+		if pos := strings.IndexByte(uri, '?'); pos >= 0 {
+			uri = uri[:pos]
+		}
+	}
+	return uri
+}
+
+func hasExt(c *Codec, ext string) bool {
+	for _, v := range c.Exts {
+		if v == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// -------------------------------------------------------------------------------------
@@ -17,19 +17,29 @@
 package cached
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"fmt"
 	"io"
-	"io/fs"
+	stdhttp "net/http"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/qiniu/x/stream"
 	"github.com/qiniu/x/stream/http"
 )
 
+const (
+	metaExt = ".meta"
+	tempExt = ".cache~"
+)
+
 // -------------------------------------------------------------------------------------
 
 var (
@@ -43,70 +53,293 @@ func init() {
 		errInit = err
 		return
 	}
-	cacheDir = root + "/qiniu.x.http/"
-	errInit = os.MkdirAll(cacheDir, 0755)
+	errInit = SetDir(root + "/qiniu.x.http/")
+}
+
+// SetDir sets the cache directory. The default is
+// os.UserCacheDir()/qiniu.x.http/.
+func SetDir(dir string) error {
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	cacheDir, errInit = dir, nil
+	return nil
 }
 
 // -------------------------------------------------------------------------------------
 
-// writeCache writes the http response to cache file.
-func writeCache(cacheDir, fname string, url string) (err error) {
-	resp, err := http.Get(url)
+// cacheName returns the name of the cache file of url. The cache metadata
+// is saved in cacheName + ".meta".
+func cacheName(url_ string) (fname string, err error) {
+	u, err := url.Parse(url_)
 	if err != nil {
 		return
 	}
-	defer resp.Body.Close()
-	f, err := os.CreateTemp(cacheDir, "*.cache~")
+	fname = path.Base(u.Path)
+	ext := path.Ext(fname)
+	hash := md5.Sum([]byte(url_))
+	hashstr := base64.RawURLEncoding.EncodeToString(hash[:])
+	fname = fmt.Sprintf("%s-%s%s", fname[:len(fname)-len(ext)], hashstr, ext)
+	return
+}
+
+// readCache reads the cache file and returns a ReadCloser.
+func readCache(cacheFile string, e *entry) (ret io.ReadCloser, err error) {
+	f, err := os.Open(cacheFile)
 	if err != nil {
 		return
 	}
-	tempFile := f.Name()
-	_, err = io.Copy(f, resp.Body)
-	if cerr := f.Close(); err == nil {
-		err = cerr
-	}
-	if err != nil {
-		os.Remove(tempFile)
-	} else if err = os.Rename(tempFile, cacheDir+fname); err != nil {
-		os.Remove(tempFile)
-	}
-	return
+	now := time.Now()
+	os.Chtimes(cacheFile+metaExt, now, now) // record the access time for Cleanup
+	return stream.WithMetadata(f, e.metadata()), nil
 }
 
-// readCache reads the cache file and returns a ReadCloser.
-func readCache(cacheFile string, _ fs.FileInfo) (ret io.ReadCloser, err error) {
-	return os.Open(cacheFile)
+// validEntry returns metadata of a complete cache file, or nil if the cache
+// file doesn't exist, is partial or is in an old format.
+func validEntry(file string) *entry {
+	e := loadEntry(file + metaExt)
+	if e == nil {
+		return nil
+	}
+	if fi, err := os.Stat(file); err != nil || fi.Size() != e.Size {
+		return nil
+	}
+	return e
 }
 
 // Open opens a http file object.
 func Open(url_ string) (ret io.ReadCloser, err error) {
+	return OpenContext(context.Background(), url_)
+}
+
+// OpenContext opens a http file object with ctx. ctx bounds downloading or
+// revalidating the file.
+//
+// A cached file is served directly while it is fresh according to its
+// Cache-Control, Expires and Last-Modified headers (RFC 9111). A stale one
+// is revalidated by a conditional request with If-None-Match and
+// If-Modified-Since; if revalidation fails by a network error or a 5xx
+// response, it is still served unless it requires revalidation
+// (Cache-Control: must-revalidate, no-cache).
+// Responses with Cache-Control: no-store are not cached.
+//
+// An interrupted download is kept and resumed by a Range request next time,
+// if the server advertises Accept-Ranges and the validator (ETag or
+// Last-Modified) is unchanged. The final size is verified against
+// Content-Length.
+func OpenContext(ctx context.Context, url_ string) (ret io.ReadCloser, err error) {
 	if errInit != nil {
-		return http.Open(url_) // fallback to direct open
+		return http.OpenContext(ctx, url_) // fallback to direct open
 	}
-	u, err := url.Parse(url_)
+	fname, err := cacheName(url_)
 	if err != nil {
 		return
 	}
-	fname := path.Base(u.Path)
-	ext := path.Ext(fname)
-	hash := md5.Sum([]byte(url_))
-	hashstr := base64.RawURLEncoding.EncodeToString(hash[:])
-	fname = fmt.Sprintf("%s-%s%s", fname[:len(fname)-len(ext)], hashstr, ext)
 	file := cacheDir + fname
-	if fi, e := os.Stat(file); e == nil {
-		if ret, err = readCache(file, fi); err == nil { // cache hit
+	e := validEntry(file)
+	if e != nil && e.fresh(time.Now()) {
+		if ret, err = readCache(file, e); err == nil { // cache hit
+			return
+		}
+		e = nil
+	}
+
+	var part *partial
+	var header stdhttp.Header
+	if e != nil {
+		header = e.conditional()
+	} else {
+		if part, err = beginPartial(cacheDir, fname); err != nil {
 			return
 		}
+		defer part.end()
+		header = part.rangeHeader()
 	}
-	if err = writeCache(cacheDir, fname, url_); err != nil {
-		return // write cache failed
+	resp, err := http.Request(ctx, "GET", url_, header)
+	if err == nil && resp.StatusCode == stdhttp.StatusRequestedRangeNotSatisfiable && header != nil && part != nil {
+		resp.Body.Close()
+		part.discard()
+		part.e = nil
+		resp, err = http.Request(ctx, "GET", url_, nil)
 	}
-	return readCache(file, nil)
+	if err != nil {
+		if e != nil && !e.mustRevalidate() {
+			return readCache(file, e) // serve stale
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == stdhttp.StatusNotModified && e != nil:
+		e.update(resp.Header)
+		if err = e.save(file + metaExt); err != nil {
+			return
+		}
+	case resp.StatusCode/100 == 2:
+		if noStore(resp.Header) && resp.StatusCode == stdhttp.StatusOK {
+			Purge(url_)
+			body := resp.Body
+			resp.Body = stdhttp.NoBody // don't close body by defer
+			return stream.WithMetadata(body, http.Metadata(resp)), nil
+		}
+		if part == nil {
+			if part, err = beginPartial(cacheDir, fname); err != nil {
+				return
+			}
+			defer part.end()
+		}
+		if e, err = part.write(resp, file, url_); err != nil {
+			return // write cache failed
+		}
+	case resp.StatusCode/100 == 5 && e != nil && !e.mustRevalidate():
+		// serve stale (stale-if-error)
+	default:
+		return nil, http.StatusError(url_, resp)
+	}
+	return readCache(file, e)
+}
+
+// Stat returns metadata of a http file without downloading it. A fresh
+// cached file is served from its cache entry; otherwise a HEAD request is
+// sent by http.Stat.
+func Stat(ctx context.Context, url_ string) (*stream.Metadata, error) {
+	if errInit == nil {
+		if fname, err := cacheName(url_); err == nil {
+			if e := validEntry(cacheDir + fname); e != nil && e.fresh(time.Now()) {
+				return e.metadata(), nil
+			}
+		}
+	}
+	return http.Stat(ctx, url_)
+}
+
+// Purge removes the cached file of url.
+func Purge(url_ string) error {
+	if errInit != nil {
+		return errInit
+	}
+	fname, err := cacheName(url_)
+	if err != nil {
+		return err
+	}
+	file := cacheDir + fname
+	err = os.Remove(file + metaExt)
+	if err2 := os.Remove(file); err == nil || os.IsNotExist(err) {
+		err = err2
+	}
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// Create starts uploading to url by a PUT request like http.Create does. The
+// cached file of url is purged when the upload completes.
+func Create(ctx context.Context, url_ string) (io.WriteCloser, error) {
+	w, err := http.Create(ctx, url_)
+	if err != nil {
+		return nil, err
+	}
+	return &uploader{w, url_}, nil
+}
+
+type uploader struct {
+	io.WriteCloser
+	url string
+}
+
+func (p *uploader) Close() error {
+	err := p.WriteCloser.Close()
+	Purge(p.url)
+	return err
 }
 
+// -------------------------------------------------------------------------------------
+
+// TempFileMaxAge is the age after which an unfinished temporary file is
+// removed by Cleanup.
+var TempFileMaxAge = time.Hour
+
+type cacheItem struct {
+	file   string
+	size   int64
+	access time.Time
+}
+
+// Cleanup bounds the total size of the cache directory to maxBytes by
+// removing least recently used files. It also removes unfinished temporary
+// files and files without valid metadata.
+func Cleanup(maxBytes int64) error {
+	if errInit != nil {
+		return errInit
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var items []*cacheItem
+	var total int64
+	for _, de := range entries {
+		name := de.Name()
+		if de.IsDir() {
+			continue
+		}
+		file := filepath.Join(cacheDir, name)
+		fi, err := de.Info()
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(name, "~") {
+			if now.Sub(fi.ModTime()) > TempFileMaxAge {
+				os.Remove(file)
+			}
+			continue
+		}
+		mi, err := os.Stat(file + metaExt)
+		if err != nil {
+			if body, ok := strings.CutSuffix(file, metaExt); ok {
+				if _, err := os.Stat(body); err == nil {
+					continue // it's metadata
+				}
+			}
+		}
+		if err != nil || validEntry(file) == nil {
+			os.Remove(file + metaExt)
+			os.Remove(file)
+			continue
+		}
+		items = append(items, &cacheItem{file, fi.Size() + mi.Size(), mi.ModTime()})
+		total += fi.Size() + mi.Size()
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].access.Before(items[j].access)
+	})
+	for _, item := range items {
+		if total <= maxBytes {
+			break
+		}
+		os.Remove(item.file + metaExt)
+		if err := os.Remove(item.file); err == nil {
+			total -= item.size
+		}
+	}
+	return nil
+}
+
+// -------------------------------------------------------------------------------------
+
 func init() {
-	stream.Register("http", Open)
-	stream.Register("https", Open)
+	stream.RegisterContext("http", OpenContext)
+	stream.RegisterContext("https", OpenContext)
+	stream.RegisterStat("http", Stat)
+	stream.RegisterStat("https", Stat)
+	stream.RegisterCreate("http", Create)
+	stream.RegisterCreate("https", Create)
 }
 
 // -------------------------------------------------------------------------------------
@@ -0,0 +1,268 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cached
+
+import (
+	"context"
+	"io"
+	stdhttp "net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/mockhttp"
+	"github.com/qiniu/x/stream"
+	"github.com/qiniu/x/stream/http"
+)
+
+func setup(t *testing.T, h stdhttp.HandlerFunc) *mockhttp.Transport {
+	if err := SetDir(t.TempDir()); err != nil {
+		t.Fatal("SetDir:", err)
+	}
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("cache.com", h)
+	old := http.Client
+	http.Client = &stdhttp.Client{Transport: tr}
+	t.Cleanup(func() { http.Client = old })
+	return tr
+}
+
+func readURL(t *testing.T, url string) string {
+	t.Helper()
+	f, err := Open(url)
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal("ReadAll:", err)
+	}
+	return string(b)
+}
+
+func TestCacheControl(t *testing.T) {
+	version := "v1"
+	tr := setup(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		h := w.Header()
+		switch r.URL.Path {
+		case "/fresh.txt":
+			h.Set("Cache-Control", "max-age=3600")
+		case "/revalidate.txt":
+			h.Set("Cache-Control", "no-cache")
+			h.Set("ETag", `"`+version+`"`)
+			if r.Header.Get("If-None-Match") == `"`+version+`"` {
+				w.WriteHeader(stdhttp.StatusNotModified)
+				return
+			}
+		case "/expired.txt":
+			h.Set("Expires", "Mon, 02 Jan 2006 15:04:05 GMT")
+		case "/nostore.txt":
+			h.Set("Cache-Control", "no-store")
+		case "/partial.txt":
+			h.Set("Content-Length", "100")
+		}
+		h.Set("Content-Type", "text/plain")
+		io.WriteString(w, version)
+	})
+	for i := 0; i < 2; i++ {
+		if _, err := Open("http://cache.com/partial.txt"); err != io.ErrUnexpectedEOF {
+			t.Fatal("Open partial:", err)
+		}
+	}
+	if n := len(tr.Calls()); n != 2 {
+		t.Fatal("partial response is cached")
+	}
+	tr.ResetCalls()
+	for _, name := range []string{"fresh", "revalidate", "expired", "nostore"} {
+		url := "http://cache.com/" + name + ".txt"
+		if ret := readURL(t, url); ret != "v1" {
+			t.Fatal("first read:", url, ret)
+		}
+		if ret := readURL(t, url); ret != "v1" {
+			t.Fatal("second read:", url, ret)
+		}
+		n := len(tr.Calls())
+		expected := map[string]int{"fresh": 1, "revalidate": 2, "expired": 2, "nostore": 2}[name]
+		if n != expected {
+			t.Fatal("requests of", name, ":", n)
+		}
+		tr.ResetCalls()
+	}
+	version = "v2"
+	if ret := readURL(t, "http://cache.com/fresh.txt"); ret != "v1" {
+		t.Fatal("fresh:", ret)
+	}
+	if ret := readURL(t, "http://cache.com/revalidate.txt"); ret != "v2" {
+		t.Fatal("revalidate:", ret)
+	}
+	if calls := tr.Calls(); calls[0].Header.Get("If-None-Match") != `"v1"` {
+		t.Fatal("revalidate: no If-None-Match")
+	}
+
+	f, err := stream.Open("http://cache.com/revalidate.txt")
+	if err != nil {
+		t.Fatal("stream.Open:", err)
+	}
+	meta := stream.MetadataOf(f)
+	f.Close()
+	if meta.Size != 2 || meta.ETag != `"v2"` || meta.ContentType != "text/plain" {
+		t.Fatal("MetadataOf:", meta)
+	}
+
+	if err = Purge("http://cache.com/fresh.txt"); err != nil {
+		t.Fatal("Purge:", err)
+	}
+	if ret := readURL(t, "http://cache.com/fresh.txt"); ret != "v2" {
+		t.Fatal("Purge:", ret)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	setup(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		io.WriteString(w, strings.Repeat("a", 1000))
+	})
+	for _, name := range []string{"a", "b", "c"} {
+		readURL(t, "http://cache.com/"+name)
+	}
+	os.WriteFile(cacheDir+"orphan", []byte("x"), 0644)
+	if err := Cleanup(2500); err != nil {
+		t.Fatal("Cleanup:", err)
+	}
+	entries, _ := os.ReadDir(cacheDir)
+	var names []string
+	for _, de := range entries {
+		names = append(names, de.Name())
+	}
+	if len(names) != 4 || strings.HasPrefix(names[0], "a-") {
+		t.Fatal("Cleanup:", names)
+	}
+}
+
+func TestResume(t *testing.T) {
+	content, etag := strings.Repeat("0123456789", 10), `"v1"`
+	tr := setup(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("ETag", etag)
+		stdhttp.ServeContent(w, r, "a.txt", time.Time{}, strings.NewReader(content))
+	})
+	url := "http://cache.com/a.txt"
+	tr.SetFault("cache.com", "", &mockhttp.Fault{Truncated: true, TruncateAt: 30})
+	if _, err := Open(url); err != io.ErrUnexpectedEOF {
+		t.Fatal("Open truncated:", err)
+	}
+	if _, err := Open(url); err != io.ErrUnexpectedEOF {
+		t.Fatal("Open truncated again:", err)
+	}
+	tr.ClearFaults()
+	tr.ResetCalls()
+	if ret := readURL(t, url); ret != content {
+		t.Fatal("resume:", ret)
+	}
+	calls := tr.Calls()
+	if len(calls) != 1 || calls[0].Header.Get("Range") != "bytes=60-" || calls[0].Header.Get("If-Range") != `"v1"` {
+		t.Fatal("resume request:", calls[0].Header)
+	}
+
+	// the validator changed: the download restarts from zero
+	Purge(url)
+	tr.SetFault("cache.com", "", &mockhttp.Fault{Truncated: true, TruncateAt: 30})
+	Open(url)
+	tr.ClearFaults()
+	content, etag = strings.Repeat("abcdefghij", 5), `"v2"`
+	if ret := readURL(t, url); ret != content {
+		t.Fatal("restart:", ret)
+	}
+	entries, _ := os.ReadDir(cacheDir)
+	for _, de := range entries {
+		if strings.HasSuffix(de.Name(), "~") || strings.HasSuffix(de.Name(), "~.meta") {
+			t.Fatal("temporary file left:", de.Name())
+		}
+	}
+}
+
+func TestLongDownload(t *testing.T) {
+	dir := t.TempDir() + "/"
+	old := TempFileMaxAge
+	TempFileMaxAge = 100 * time.Millisecond
+	defer func() { TempFileMaxAge = old }()
+
+	p, err := beginPartial(dir, "a")
+	if err != nil || p.lock == "" {
+		t.Fatal("beginPartial:", p, err)
+	}
+	os.WriteFile(p.file, []byte("part"), 0644)
+	stop := p.keepAlive()
+	time.Sleep(3 * TempFileMaxAge)
+	p2, err := beginPartial(dir, "a")
+	stop()
+	if err != nil || p2.lock != "" {
+		t.Fatal("a running download is taken as stale:", p2, err)
+	}
+	p2.end()
+	p.end()
+}
+
+func TestStat(t *testing.T) {
+	tr := setup(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		w.Header().Set("Content-Length", "3")
+		if r.Method != "HEAD" {
+			io.WriteString(w, "abc")
+		}
+	})
+	meta, err := stream.Stat(context.Background(), "http://cache.com/stat.txt")
+	if err != nil || meta.Size != 3 {
+		t.Fatal("Stat:", meta, err)
+	}
+	if calls := tr.Calls(); len(calls) != 1 || calls[0].Method != "HEAD" {
+		t.Fatal("Stat should send a HEAD request:", calls)
+	}
+	readURL(t, "http://cache.com/stat.txt")
+	tr.ResetCalls()
+	if meta, err = stream.Stat(context.Background(), "http://cache.com/stat.txt"); err != nil || meta.Size != 3 {
+		t.Fatal("Stat:", meta, err)
+	}
+	if n := len(tr.Calls()); n != 0 {
+		t.Fatal("Stat of a fresh cached file sends requests:", n)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	fail := false
+	setup(t, func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+		if fail {
+			w.WriteHeader(stdhttp.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Expires", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.URL.Path == "/must.txt" {
+			w.Header().Set("Cache-Control", "must-revalidate")
+		}
+		io.WriteString(w, "v1")
+	})
+	readURL(t, "http://cache.com/stale.txt")
+	readURL(t, "http://cache.com/must.txt")
+	fail = true
+	if ret := readURL(t, "http://cache.com/stale.txt"); ret != "v1" {
+		t.Fatal("stale-if-error:", ret)
+	}
+	if _, err := Open("http://cache.com/must.txt"); err == nil {
+		t.Fatal("must-revalidate: no error")
+	}
+}
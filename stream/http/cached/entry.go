@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cached
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qiniu/x/stream"
+)
+
+// HeuristicMaxAge is the freshness lifetime of a response that has neither
+// explicit freshness (Cache-Control max-age, Expires) nor Last-Modified.
+var HeuristicMaxAge = 24 * time.Hour
+
+// storedHeaders lists response headers saved in cache metadata.
+var storedHeaders = []string{
+	"Accept-Ranges", "Age", "Cache-Control", "Content-Type", "Date", "ETag", "Expires", "Last-Modified",
+}
+
+// -------------------------------------------------------------------------------------
+
+// entry represents metadata of a cached response. It is saved next to the
+// cached body, and is written only after the body is complete.
+type entry struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Size   int64       `json:"size"`
+	Stored time.Time   `json:"stored"` // response time of the last (re)validation
+}
+
+func newEntry(url string, resp *http.Response, size int64) *entry {
+	e := &entry{URL: url, Header: make(http.Header), Size: size}
+	e.update(resp.Header)
+	return e
+}
+
+// update merges headers of a 200 or 304 response, and marks the entry as
+// just validated.
+func (p *entry) update(h http.Header) {
+	p.Header.Del("Age")
+	for _, k := range storedHeaders {
+		if v := h.Values(k); v != nil {
+			p.Header[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	p.Stored = time.Now()
+}
+
+func loadEntry(metaFile string) *entry {
+	b, err := os.ReadFile(metaFile)
+	if err != nil {
+		return nil
+	}
+	e := new(entry)
+	if json.Unmarshal(b, e) != nil || e.Header == nil {
+		return nil
+	}
+	return e
+}
+
+func (p *entry) save(metaFile string) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaFile, b)
+}
+
+func writeFileAtomic(file string, data []byte) error {
+	tmp := file + "~"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// -------------------------------------------------------------------------------------
+
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range h.Values("Cache-Control") {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			k, val, _ := strings.Cut(item, "=")
+			cc[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(val), `"`)
+		}
+	}
+	return cc
+}
+
+func (p cacheControl) has(directive string) bool {
+	_, ok := p[directive]
+	return ok
+}
+
+// noStore reports whether a response must not be stored. As a private
+// cache, responses with "Cache-Control: private" are stored.
+func noStore(h http.Header) bool {
+	return parseCacheControl(h).has("no-store")
+}
+
+// lifetime returns the freshness lifetime of the entry (RFC 9111, 4.2.1).
+func (p *entry) lifetime() time.Duration {
+	h := p.Header
+	cc := parseCacheControl(h)
+	if cc.has("no-cache") {
+		return 0
+	}
+	if v, ok := cc["max-age"]; ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Duration(n) * time.Second
+		}
+		return 0
+	}
+	date := p.Stored
+	if v := h.Get("Date"); v != "" {
+		if t, err := http.ParseTime(v); err == nil {
+			date = t
+		}
+	}
+	if v := h.Get("Expires"); v != "" {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			return 0 // invalid Expires means already expired
+		}
+		return t.Sub(date)
+	}
+	if v := h.Get("Last-Modified"); v != "" {
+		if t, err := http.ParseTime(v); err == nil && date.After(t) {
+			return date.Sub(t) / 10
+		}
+	}
+	return HeuristicMaxAge
+}
+
+// age returns the current age of the entry (RFC 9111, 4.2.3).
+func (p *entry) age(now time.Time) time.Duration {
+	age := now.Sub(p.Stored)
+	if v := p.Header.Get("Age"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			age += time.Duration(n) * time.Second
+		}
+	}
+	return age
+}
+
+func (p *entry) fresh(now time.Time) bool {
+	return p.age(now) < p.lifetime()
+}
+
+// mustRevalidate reports whether a stale entry must not be served when
+// revalidation fails.
+func (p *entry) mustRevalidate() bool {
+	cc := parseCacheControl(p.Header)
+	return cc.has("must-revalidate") || cc.has("no-cache")
+}
+
+// conditional returns headers of a conditional request to revalidate the
+// entry, or nil if the entry has no validator.
+func (p *entry) conditional() http.Header {
+	var h http.Header
+	if v := p.Header.Get("ETag"); v != "" {
+		h = http.Header{"If-None-Match": {v}}
+	}
+	if v := p.Header.Get("Last-Modified"); v != "" {
+		if h == nil {
+			h = make(http.Header)
+		}
+		h.Set("If-Modified-Since", v)
+	}
+	return h
+}
+
+// validator returns the validator used in If-Range: a strong ETag, or else
+// Last-Modified.
+func (p *entry) validator() string {
+	if v := p.Header.Get("ETag"); v != "" && !strings.HasPrefix(v, "W/") {
+		return v
+	}
+	return p.Header.Get("Last-Modified")
+}
+
+// resumable reports whether a partial download of the entry can be resumed
+// by a Range request.
+func (p *entry) resumable() bool {
+	return p.Size > 0 && p.Header.Get("Accept-Ranges") == "bytes" && p.validator() != ""
+}
+
+func (p *entry) metadata() *stream.Metadata {
+	h := p.Header
+	meta := &stream.Metadata{
+		Size:        p.Size,
+		ContentType: h.Get("Content-Type"),
+		ETag:        h.Get("ETag"),
+	}
+	if lm := h.Get("Last-Modified"); lm != "" {
+		meta.ModTime, _ = http.ParseTime(lm)
+	}
+	return meta
+}
+
+// -------------------------------------------------------------------------------------
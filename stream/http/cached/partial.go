@@ -0,0 +1,197 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cached
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	partExt = ".part~"
+	lockExt = ".lock~"
+)
+
+var errInvalidRange = errors.New("invalid Content-Range of resumed download")
+
+// -------------------------------------------------------------------------------------
+
+// partial represents an unfinished download of a cache file. It is kept as
+// file + ".part~" (with its validator in file + ".part~.meta") so that a
+// dropped download can be resumed by a Range request, if the server
+// advertises Accept-Ranges and the validator (ETag/Last-Modified) is
+// unchanged.
+type partial struct {
+	file   string // the partial file
+	lock   string // the lock file; empty if the download is not resumable
+	e      *entry // validator of the partial file; nil if there is nothing to resume
+	offset int64
+}
+
+// beginPartial starts downloading file. If another download of the same
+// file is in progress, it downloads into a temporary file which is not
+// resumable.
+func beginPartial(cacheDir, fname string) (p *partial, err error) {
+	file := cacheDir + fname
+	lock := file + lockExt
+	f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		if fi, e := os.Stat(lock); e == nil && time.Since(fi.ModTime()) > TempFileMaxAge {
+			os.Remove(lock) // stale lock
+			f, err = os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		}
+	}
+	if err != nil {
+		tmp, err := os.CreateTemp(cacheDir, "*"+tempExt)
+		if err != nil {
+			return nil, err
+		}
+		tmp.Close()
+		return &partial{file: tmp.Name()}, nil
+	}
+	f.Close()
+
+	p = &partial{file: file + partExt, lock: lock}
+	if e := loadEntry(p.file + metaExt); e != nil && e.resumable() {
+		if fi, err := os.Stat(p.file); err == nil && fi.Size() > 0 && fi.Size() < e.Size {
+			p.e, p.offset = e, fi.Size()
+		}
+	}
+	return
+}
+
+// end releases the partial download.
+func (p *partial) end() {
+	if p.lock != "" {
+		os.Remove(p.lock)
+	} else {
+		os.Remove(p.file) // not resumable
+	}
+}
+
+// keepAlive touches the partial file and the lock periodically until stop
+// is called, so that a download lasting longer than TempFileMaxAge isn't
+// taken as stale by another download of the file, or removed by Cleanup.
+func (p *partial) keepAlive() (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(TempFileMaxAge / 4)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				os.Chtimes(p.file, now, now)
+				if p.lock != "" {
+					os.Chtimes(p.lock, now, now)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// rangeHeader returns headers of a request resuming the partial download,
+// or nil if there is nothing to resume.
+func (p *partial) rangeHeader() http.Header {
+	if p.e == nil {
+		return nil
+	}
+	return http.Header{
+		"Range":    {"bytes=" + strconv.FormatInt(p.offset, 10) + "-"},
+		"If-Range": {p.e.validator()},
+	}
+}
+
+func (p *partial) discard() {
+	os.Remove(p.file + metaExt)
+	os.Remove(p.file)
+}
+
+// write writes the http response (200 or 206) into the partial file. When
+// it completes, the partial file is renamed to the cache file and the cache
+// metadata is saved.
+func (p *partial) write(resp *http.Response, file, url string) (e *entry, err error) {
+	var f *os.File
+	offset := int64(0)
+	if resp.StatusCode == http.StatusPartialContent {
+		if p.e == nil || !checkContentRange(resp.Header.Get("Content-Range"), p.offset, p.e.Size) {
+			p.discard()
+			return nil, errInvalidRange
+		}
+		e, offset = p.e, p.offset
+		e.update(resp.Header)
+		f, err = os.OpenFile(p.file, os.O_WRONLY|os.O_APPEND, 0644)
+	} else {
+		e = newEntry(url, resp, resp.ContentLength)
+		if p.lock != "" {
+			if e.resumable() {
+				err = e.save(p.file + metaExt)
+			} else {
+				err = os.Remove(p.file + metaExt)
+				if os.IsNotExist(err) {
+					err = nil
+				}
+			}
+		}
+		if err == nil {
+			f, err = os.OpenFile(p.file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+		}
+	}
+	if err != nil {
+		return
+	}
+	stop := p.keepAlive()
+	n, err := io.Copy(f, resp.Body)
+	stop()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	size := offset + n
+	if err == nil && e.Size >= 0 && size != e.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		if p.lock == "" || !e.resumable() || size > e.Size {
+			p.discard()
+		}
+		return
+	}
+	e.Size = size
+	if err = os.Rename(p.file, file); err != nil {
+		p.discard()
+		return
+	}
+	os.Remove(p.file + metaExt)
+	err = e.save(file + metaExt)
+	return
+}
+
+// checkContentRange checks Content-Range "bytes <offset>-<size-1>/<size>".
+func checkContentRange(cr string, offset, size int64) bool {
+	expected := fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size)
+	return strings.TrimSpace(cr) == expected
+}
+
+// -------------------------------------------------------------------------------------
@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"sync/atomic"
+)
+
+var (
+	// ErrTruncated is returned when the server responds to an upload before
+	// the whole body is sent.
+	ErrTruncated = errors.New("upload truncated by early response")
+)
+
+// -------------------------------------------------------------------------------------
+
+// Create starts uploading to url by a PUT request. See CreateMethod.
+func Create(ctx context.Context, url string) (io.WriteCloser, error) {
+	return CreateMethod(ctx, "PUT", url, nil)
+}
+
+// CreateMethod starts uploading to url by a request of method (eg. PUT or
+// POST) with the specified header (which may be nil). What is written is
+// sent as the request body with chunked transfer encoding. Close waits for
+// the response and returns an error if its status isn't 2xx, or if the
+// server responds before the whole body is sent. Canceling ctx aborts the
+// request; Client.Timeout doesn't apply, since an upload may take
+// arbitrarily long. It applies DefaultUserAgent, ReqHeaderProc and the
+// security policy like Get does.
+func CreateMethod(ctx context.Context, method, url string, header http.Header) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	body := &uploadBody{PipeReader: pr}
+	req, err := newRequest(ctx, method, url, header, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = -1 // chunked
+	c := *Client
+	c.Timeout = 0 // bounded by ctx
+	w := &uploader{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		resp, err := send(&c, req)
+		if err == nil {
+			if resp.StatusCode/100 != 2 {
+				err = StatusError(url, resp)
+			} else if !body.eof.Load() {
+				err = &fs.PathError{Op: "http.Create", Err: ErrTruncated, Path: url}
+			}
+			resp.Body.Close()
+		}
+		w.err = err
+		pr.CloseWithError(err) // unblock writers if the request ends early
+	}()
+	return w, nil
+}
+
+// uploadBody is the request body of an upload, which records whether it
+// has been read to the end.
+type uploadBody struct {
+	*io.PipeReader
+	eof atomic.Bool
+}
+
+func (p *uploadBody) Read(b []byte) (n int, err error) {
+	n, err = p.PipeReader.Read(b)
+	if err == io.EOF {
+		p.eof.Store(true)
+	}
+	return
+}
+
+type uploader struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error // valid after done is closed
+}
+
+func (p *uploader) Write(b []byte) (n int, err error) {
+	n, err = p.pw.Write(b)
+	if err != nil {
+		<-p.done
+		if p.err != nil {
+			err = p.err
+		}
+	}
+	return
+}
+
+func (p *uploader) Close() error {
+	p.pw.Close()
+	<-p.done
+	return p.err
+}
+
+// -------------------------------------------------------------------------------------
@@ -17,10 +17,14 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/qiniu/x/stream"
 )
 
 var (
@@ -40,11 +44,47 @@ var (
 
 // Open opens the URL and returns the response body as an io.ReadCloser.
 func Open(url string) (io.ReadCloser, error) {
-	resp, err := Get(url)
+	return OpenContext(context.Background(), url)
+}
+
+// OpenContext opens the URL with ctx and returns the response body as an
+// io.ReadCloser. Canceling ctx also aborts reading the body.
+func OpenContext(ctx context.Context, url string) (io.ReadCloser, error) {
+	resp, err := GetContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return stream.WithMetadata(resp.Body, Metadata(resp)), nil
+}
+
+// Stat sends a HEAD request to the specified URL and returns metadata of
+// the resource.
+func Stat(ctx context.Context, url string) (*stream.Metadata, error) {
+	resp, err := do(ctx, "HEAD", url)
 	if err != nil {
 		return nil, err
 	}
-	return resp.Body, nil
+	resp.Body.Close()
+	return Metadata(resp), nil
+}
+
+// Metadata returns metadata of the resource from a http response.
+func Metadata(resp *http.Response) *stream.Metadata {
+	h := resp.Header
+	meta := &stream.Metadata{
+		Size:        resp.ContentLength,
+		ContentType: h.Get("Content-Type"),
+		ETag:        h.Get("ETag"),
+	}
+	if meta.Size < 0 {
+		if v, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil {
+			meta.Size = v
+		}
+	}
+	if lm := h.Get("Last-Modified"); lm != "" {
+		meta.ModTime, _ = http.ParseTime(lm)
+	}
+	return meta
 }
 
 // Get sends a GET request to the specified URL.
@@ -54,26 +94,78 @@ func Open(url string) (io.ReadCloser, error) {
 // provide URLs to internal network resources (e.g., http://localhost:6379,
 // http://169.254.169.254/latest/meta-data/) to scan internal networks,
 // access cloud metadata services, or interact with internal APIs.
+// Use SetPolicy to restrict the URLs that may be fetched.
 func Get(url string) (resp *http.Response, err error) {
-	req, err := http.NewRequest("GET", url, nil)
+	return GetContext(context.Background(), url)
+}
+
+// GetContext sends a GET request to the specified URL with ctx.
+// See Get for security considerations.
+func GetContext(ctx context.Context, url string) (resp *http.Response, err error) {
+	return do(ctx, "GET", url)
+}
+
+func do(ctx context.Context, method, url string) (resp *http.Response, err error) {
+	if resp, err = Request(ctx, method, url, nil); err != nil {
+		return
+	}
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		return nil, StatusError(url, resp)
+	}
+	return
+}
+
+// Request sends a request with the specified header (which may be nil) and
+// returns the response of any status code. It applies DefaultUserAgent,
+// ReqHeaderProc and the security policy like Get does.
+func Request(ctx context.Context, method, url string, header http.Header) (resp *http.Response, err error) {
+	req, err := newRequest(ctx, method, url, header, nil)
+	if err != nil {
+		return
+	}
+	return send(Client, req)
+}
+
+func newRequest(ctx context.Context, method, url string, header http.Header, body io.Reader) (req *http.Request, err error) {
+	req, err = http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
 	if DefaultUserAgent != "" {
 		req.Header.Set("User-Agent", DefaultUserAgent)
 	}
 	if ReqHeaderProc != nil {
 		ReqHeaderProc(req)
 	}
-	if resp, err = Client.Do(req); err != nil {
+	if p := policy; p != nil {
+		if err = p.CheckURL(req.URL); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func send(c *http.Client, req *http.Request) (resp *http.Response, err error) {
+	p := policy
+	if resp, err = c.Do(req); err != nil {
 		return
 	}
-	if resp.StatusCode/100 != 2 {
-		resp.Body.Close()
-		resp, err = nil, fmt.Errorf(
-			"HTTP request to %s failed with status: %s", url, resp.Status)
+	if p != nil {
+		if err = p.limitBody(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
 	}
 	return
 }
 
+// StatusError returns the error of a failed request to url.
+func StatusError(url string, resp *http.Response) error {
+	return fmt.Errorf("HTTP request to %s failed with status: %s", url, resp.Status)
+}
+
 // -------------------------------------------------------------------------------------
@@ -22,6 +22,10 @@ import (
 )
 
 func init() {
-	stream.Register("http", http.Open)
-	stream.Register("https", http.Open)
+	stream.RegisterContext("http", http.OpenContext)
+	stream.RegisterContext("https", http.OpenContext)
+	stream.RegisterStat("http", http.Stat)
+	stream.RegisterStat("https", http.Stat)
+	stream.RegisterCreate("http", http.Create)
+	stream.RegisterCreate("https", http.Create)
 }
@@ -0,0 +1,247 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrForbidden is returned when a URL or an address is not allowed by
+	// the security policy.
+	ErrForbidden = errors.New("forbidden by security policy")
+
+	// ErrBodyTooLarge is returned when a response body exceeds
+	// Policy.MaxBodySize.
+	ErrBodyTooLarge = errors.New("response body too large")
+)
+
+// -------------------------------------------------------------------------------------
+
+// Policy restricts the URLs that Get and Open may fetch, to mitigate
+// Server-Side Request Forgery (SSRF).
+type Policy struct {
+	// Schemes lists allowed URL schemes. Empty means "http" and "https".
+	Schemes []string
+
+	// Hosts lists allowed host names. A name starting with "*." matches its
+	// subdomains. Empty means any host.
+	Hosts []string
+
+	// AllowCIDRs lists address ranges that are always allowed, even if they
+	// are private, loopback or link-local.
+	AllowCIDRs []netip.Prefix
+
+	// DenyCIDRs lists address ranges that are denied.
+	DenyCIDRs []netip.Prefix
+
+	// AllowPrivate allows private, loopback, link-local, unspecified and
+	// shared (CGNAT) addresses, which are denied by default.
+	AllowPrivate bool
+
+	// MaxRedirects limits the number of redirects. 0 means 10.
+	MaxRedirects int
+
+	// MaxBodySize limits the size of response bodies. 0 means no limit.
+	MaxBodySize int64
+}
+
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func (p *Policy) forbidden(what string) error {
+	return fmt.Errorf("%s: %w", what, ErrForbidden)
+}
+
+// CheckIP checks if an address is allowed.
+func (p *Policy) CheckIP(ip netip.Addr) error {
+	ip = ip.Unmap()
+	for _, cidr := range p.AllowCIDRs {
+		if cidr.Contains(ip) {
+			return nil
+		}
+	}
+	for _, cidr := range p.DenyCIDRs {
+		if cidr.Contains(ip) {
+			return p.forbidden("address " + ip.String())
+		}
+	}
+	if !p.AllowPrivate {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+			ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+			ip.IsInterfaceLocalMulticast() || sharedAddrSpace.Contains(ip) ||
+			(ip.Is4() && ip.As4()[0] == 0) {
+			return p.forbidden("address " + ip.String())
+		}
+	}
+	return nil
+}
+
+// CheckURL checks if a URL is allowed. If its host is an IP address, the
+// address is checked too; host names are checked after DNS resolution by
+// the Transport returned by NewTransport.
+func (p *Policy) CheckURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if len(p.Schemes) > 0 {
+		if !containsFold(p.Schemes, scheme) {
+			return p.forbidden("scheme " + scheme)
+		}
+	} else if scheme != "http" && scheme != "https" {
+		return p.forbidden("scheme " + scheme)
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if len(p.Hosts) > 0 && !matchHost(p.Hosts, host) {
+		return p.forbidden("host " + host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return p.CheckIP(ip)
+	}
+	return nil
+}
+
+func containsFold(s []string, e string) bool {
+	for _, a := range s {
+		if strings.EqualFold(a, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, pat := range patterns {
+		pat = strings.ToLower(pat)
+		if suffix, ok := strings.CutPrefix(pat, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == pat {
+			return true
+		}
+	}
+	return false
+}
+
+// control checks resolved addresses at dial time, to defeat DNS rebinding.
+func (p *Policy) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	return p.CheckIP(ip)
+}
+
+// CheckRedirect re-validates redirected URLs. It can be used as
+// http.Client.CheckRedirect.
+func (p *Policy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	max := p.MaxRedirects
+	if max == 0 {
+		max = 10
+	}
+	if len(via) >= max {
+		return fmt.Errorf("stopped after %d redirects", max)
+	}
+	return p.CheckURL(req.URL)
+}
+
+// NewTransport creates a http.Transport which checks addresses after DNS
+// resolution. It doesn't use proxies, which would bypass the check.
+func (p *Policy) NewTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.control,
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+// NewClient creates a http.Client that enforces the policy.
+func (p *Policy) NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport:     p.NewTransport(),
+		CheckRedirect: p.CheckRedirect,
+		Timeout:       timeout,
+	}
+}
+
+func (p *Policy) limitBody(resp *http.Response) error {
+	max := p.MaxBodySize
+	if max <= 0 {
+		return nil
+	}
+	if resp.ContentLength > max {
+		return ErrBodyTooLarge
+	}
+	resp.Body = &limitedBody{resp.Body, max}
+	return nil
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remain int64
+}
+
+func (p *limitedBody) Read(b []byte) (n int, err error) {
+	if p.remain < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(b)) > p.remain+1 {
+		b = b[:p.remain+1]
+	}
+	n, err = p.ReadCloser.Read(b)
+	if p.remain -= int64(n); p.remain < 0 {
+		n, err = n+int(p.remain), ErrBodyTooLarge
+	}
+	return
+}
+
+// -------------------------------------------------------------------------------------
+
+var policy *Policy
+
+// SetPolicy sets the security policy of Get and Open. Client is replaced
+// by p.NewClient so that addresses are checked at dial time and redirects
+// are re-validated; URLs and body sizes are checked even if Client is
+// changed later. If p is nil, the policy is removed but Client is kept.
+func SetPolicy(p *Policy) {
+	policy = p
+	if p != nil {
+		Client = p.NewClient(Client.Timeout)
+	}
+}
+
+// GetPolicy returns the security policy set by SetPolicy.
+func GetPolicy() *Policy {
+	return policy
+}
+
+// -------------------------------------------------------------------------------------
@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiniu/x/mockhttp"
+)
+
+func TestCheckURL(t *testing.T) {
+	p := &Policy{Hosts: []string{"example.com", "*.qiniu.com"}}
+	cases := []struct {
+		url string
+		ok  bool
+	}{
+		{"http://example.com/a", true},
+		{"https://cdn.qiniu.com/a", true},
+		{"ftp://example.com/a", false},
+		{"http://evil.com/a", false},
+		{"http://127.0.0.1/", false},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.url)
+		if err := p.CheckURL(u); (err == nil) != c.ok {
+			t.Fatal("CheckURL:", c.url, err)
+		}
+	}
+
+	p = &Policy{
+		AllowCIDRs: []netip.Prefix{netip.MustParsePrefix("10.1.0.0/16")},
+		DenyCIDRs:  []netip.Prefix{netip.MustParsePrefix("8.8.8.0/24")},
+	}
+	ips := []struct {
+		ip string
+		ok bool
+	}{
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"8.8.8.8", false},
+		{"10.1.2.3", true},
+		{"1.1.1.1", true},
+	}
+	for _, c := range ips {
+		if err := p.CheckIP(netip.MustParseAddr(c.ip)); (err == nil) != c.ok {
+			t.Fatal("CheckIP:", c.ip, err)
+		}
+	}
+}
+
+func TestDialCheck(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	}))
+	defer svr.Close()
+
+	// "localhost" passes the URL check, but is denied after DNS resolution.
+	u := strings.Replace(svr.URL, "127.0.0.1", "localhost", 1)
+	p := &Policy{}
+	_, err := p.NewClient(time.Second).Get(u)
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatal("dial check:", err)
+	}
+	p.AllowCIDRs = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+	resp, err := p.NewClient(time.Second).Get(u)
+	if err != nil {
+		t.Fatal("allowed dial:", err)
+	}
+	resp.Body.Close()
+}
+
+func TestPolicy(t *testing.T) {
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("example.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/big":
+			io.WriteString(w, strings.Repeat("a", 100))
+		default:
+			io.WriteString(w, "hello")
+		}
+	}))
+	oldClient := Client
+	defer func() {
+		SetPolicy(nil)
+		Client = oldClient
+	}()
+	p := &Policy{MaxBodySize: 10}
+	SetPolicy(p)
+	if GetPolicy() != p || Client.CheckRedirect == nil {
+		t.Fatal("SetPolicy failed")
+	}
+	Client.Transport = tr // keep the redirect check, replace the dialer
+
+	if _, err := Open("http://169.254.169.254/latest/meta-data/"); !errors.Is(err, ErrForbidden) {
+		t.Fatal("Open metadata address:", err)
+	}
+	if _, err := Open("http://example.com/redirect"); !errors.Is(err, ErrForbidden) {
+		t.Fatal("Open redirect:", err)
+	}
+	f, err := Open("http://example.com/big")
+	if err != nil {
+		t.Fatal("Open big:", err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != ErrBodyTooLarge || len(b) != 10 {
+		t.Fatal("Read big:", len(b), err)
+	}
+	f, err = Open("http://example.com/small")
+	if err != nil {
+		t.Fatal("Open small:", err)
+	}
+	b, err = io.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "hello" {
+		t.Fatal("Read small:", string(b), err)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package inline
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/qiniu/x/stream"
+)
+
+// -------------------------------------------------------------------------------------
+
+type dataReader struct {
+	*bytes.Reader
+	meta *stream.Metadata
+}
+
+func (p *dataReader) Close() error {
+	return nil
+}
+
+// Metadata returns metadata of the data URL object. Its Params holds the
+// media type parameters, eg. charset.
+func (p *dataReader) Metadata() *stream.Metadata {
+	return p.meta
+}
+
+// ParseData parses a data URL (RFC 2397):
+//
+//	data:[<mediatype>][;base64],<data>
+//
+// It returns the decoded data and its media type and parameters. The media
+// type defaults to "text/plain;charset=US-ASCII".
+func ParseData(url_ string) (data []byte, mediatype string, params map[string]string, err error) {
+	file, ok := strings.CutPrefix(url_, "data:")
+	if !ok {
+		return nil, "", nil, fs.ErrInvalid
+	}
+	header, body, ok := strings.Cut(file, ",")
+	if !ok {
+		return nil, "", nil, fs.ErrInvalid
+	}
+	header, isBase64 := strings.CutSuffix(header, ";base64")
+	mediatype, params = "text/plain", map[string]string{}
+	if header == "" {
+		params["charset"] = "US-ASCII"
+	} else {
+		if strings.HasPrefix(header, ";") {
+			header = mediatype + header
+		}
+		if header, err = url.PathUnescape(header); err != nil {
+			return
+		}
+		if mediatype, params, err = mime.ParseMediaType(header); err != nil {
+			return
+		}
+	}
+	if body, err = url.PathUnescape(body); err != nil {
+		return
+	}
+	if !isBase64 {
+		return []byte(body), mediatype, params, nil
+	}
+	body = strings.Map(func(c rune) rune {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return c
+	}, body)
+	enc := base64.StdEncoding
+	if !strings.HasSuffix(body, "=") && len(body)%4 != 0 {
+		enc = base64.RawStdEncoding
+	}
+	data, err = enc.DecodeString(body)
+	return
+}
+
+// OpenData opens a data URL object as a stream.
+// The url format: data:[<mediatype>][;base64],<data>
+func OpenData(url string) (io.ReadCloser, error) {
+	data, mediatype, params, err := ParseData(url)
+	if err != nil {
+		return nil, err
+	}
+	meta := &stream.Metadata{
+		Size:        int64(len(data)),
+		ContentType: mime.FormatMediaType(mediatype, params),
+		Params:      params,
+	}
+	return &dataReader{bytes.NewReader(data), meta}, nil
+}
+
+// OpenDataContext opens a data URL object as a stream. See OpenData.
+func OpenDataContext(ctx context.Context, url string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return OpenData(url)
+}
+
+func init() {
+	stream.RegisterContext("data", OpenDataContext)
+}
+
+// -------------------------------------------------------------------------------------
@@ -17,6 +17,7 @@
 package inline
 
 import (
+	"context"
 	"io"
 	"strings"
 
@@ -24,13 +25,18 @@ import (
 )
 
 type nilCloser struct {
-	io.Reader
+	*strings.Reader
 }
 
 func (p *nilCloser) Close() error {
 	return nil
 }
 
+// Metadata returns metadata of the inline text object.
+func (p *nilCloser) Metadata() *stream.Metadata {
+	return &stream.Metadata{Size: p.Size(), ContentType: "text/plain; charset=utf-8"}
+}
+
 // Open opens a inline text object as a stream.
 // The url format: inline:<text>
 func Open(url string) (io.ReadCloser, error) {
@@ -39,6 +45,14 @@ func Open(url string) (io.ReadCloser, error) {
 	return &nilCloser{r}, nil
 }
 
+// OpenContext opens a inline text object as a stream. See Open.
+func OpenContext(ctx context.Context, url string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return Open(url)
+}
+
 func init() {
-	stream.Register("inline", Open)
+	stream.RegisterContext("inline", OpenContext)
 }
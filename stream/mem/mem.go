@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mem implements the "mem:" scheme, an in-memory file system for
+// tests. A file is created by stream.Create("mem:name") and becomes visible
+// when the writer is closed.
+package mem
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qiniu/x/stream"
+)
+
+// -------------------------------------------------------------------------------------
+
+type file struct {
+	data    []byte
+	modTime time.Time
+}
+
+func (p *file) metadata(name string) *stream.Metadata {
+	return &stream.Metadata{
+		Size:        int64(len(p.data)),
+		ModTime:     p.modTime,
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+	}
+}
+
+var (
+	mutex sync.RWMutex
+	files = map[string]*file{}
+)
+
+func get(name string) (*file, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	f, ok := files[name]
+	return f, ok
+}
+
+// Get returns content of the named file.
+func Get(name string) ([]byte, bool) {
+	if f, ok := get(name); ok {
+		return f.data, true
+	}
+	return nil, false
+}
+
+// Set sets content of the named file.
+func Set(name string, data []byte) {
+	mutex.Lock()
+	files[name] = &file{data, time.Now()}
+	mutex.Unlock()
+}
+
+// Remove removes the named file.
+func Remove(name string) {
+	mutex.Lock()
+	delete(files, name)
+	mutex.Unlock()
+}
+
+// Reset removes all files.
+func Reset() {
+	mutex.Lock()
+	files = map[string]*file{}
+	mutex.Unlock()
+}
+
+// -------------------------------------------------------------------------------------
+
+type reader struct {
+	*bytes.Reader
+	meta *stream.Metadata
+}
+
+func (p *reader) Close() error {
+	return nil
+}
+
+// Metadata returns metadata of the in-memory file.
+func (p *reader) Metadata() *stream.Metadata {
+	return p.meta
+}
+
+// Open opens an in-memory file.
+// The url format: mem:<name>
+func Open(ctx context.Context, url string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(url, "mem:")
+	f, ok := get(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: url, Err: fs.ErrNotExist}
+	}
+	return &reader{bytes.NewReader(f.data), f.metadata(name)}, nil
+}
+
+// Stat returns metadata of an in-memory file.
+func Stat(ctx context.Context, url string) (*stream.Metadata, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(url, "mem:")
+	f, ok := get(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: url, Err: fs.ErrNotExist}
+	}
+	return f.metadata(name), nil
+}
+
+type writer struct {
+	bytes.Buffer
+	ctx  context.Context
+	name string
+}
+
+func (p *writer) Close() error {
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	Set(p.name, p.Bytes())
+	return nil
+}
+
+// Create creates an in-memory file, which is stored when the returned
+// writer is closed.
+func Create(ctx context.Context, url string) (io.WriteCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &writer{ctx: ctx, name: strings.TrimPrefix(url, "mem:")}, nil
+}
+
+func init() {
+	stream.RegisterContext("mem", Open)
+	stream.RegisterStat("mem", Stat)
+	stream.RegisterCreate("mem", Create)
+}
+
+// -------------------------------------------------------------------------------------
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"time"
+)
+
+// -------------------------------------------------------------------------------------
+
+// Metadata represents metadata of a resource.
+type Metadata struct {
+	Size        int64     // -1 if unknown
+	ModTime     time.Time // zero if unknown
+	ContentType string    // empty if unknown
+	ETag        string    // empty if unknown
+
+	// Params holds extra metadata specific to a scheme (eg. media type
+	// parameters). It may be nil.
+	Params map[string]string
+}
+
+// MetadataReader is an optional interface that a stream returned by an open
+// function may implement to report metadata of the resource.
+type MetadataReader interface {
+	Metadata() *Metadata
+}
+
+// StatFunc defines the function type for getting metadata of a resource by
+// URL without reading it.
+type StatFunc = func(ctx context.Context, url string) (*Metadata, error)
+
+var (
+	staters = map[string]StatFunc{}
+)
+
+// RegisterStat registers a scheme with a stat function. A scheme without a
+// stat function is opened and then its MetadataReader is used.
+func RegisterStat(scheme string, stat StatFunc) {
+	staters[scheme] = stat
+}
+
+// Stat returns metadata of the resource identified by the given URI.
+func Stat(ctx context.Context, uri string) (*Metadata, error) {
+	scheme := schemeOf(uri)
+	if scheme == "" {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fi, err := os.Stat(uri)
+		if err != nil {
+			return nil, err
+		}
+		return FileMetadata(fi), nil
+	}
+	if stat, ok := staters[scheme]; ok {
+		return stat(ctx, uri)
+	}
+	if _, ok := openers[scheme]; !ok {
+		return nil, &fs.PathError{Op: "stream.Stat", Err: ErrUnknownScheme, Path: uri}
+	}
+	f, err := OpenRaw(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return MetadataOf(f), nil
+}
+
+// MetadataOf returns metadata of an opened stream. It returns a Metadata
+// with Size -1 if the stream provides nothing.
+func MetadataOf(r io.Reader) *Metadata {
+	switch f := r.(type) {
+	case MetadataReader:
+		return f.Metadata()
+	case *os.File:
+		if fi, err := f.Stat(); err == nil {
+			return FileMetadata(fi)
+		}
+	}
+	return &Metadata{Size: -1}
+}
+
+// FileMetadata returns metadata of a file. Its content type is guessed by
+// the file extension.
+func FileMetadata(fi fs.FileInfo) *Metadata {
+	return &Metadata{
+		Size:        fi.Size(),
+		ModTime:     fi.ModTime(),
+		ContentType: mime.TypeByExtension(path.Ext(fi.Name())),
+	}
+}
+
+// -------------------------------------------------------------------------------------
+
+// WithMetadata returns a stream that reads from rc and reports meta as its
+// metadata.
+func WithMetadata(rc io.ReadCloser, meta *Metadata) io.ReadCloser {
+	return &metaReadCloser{rc, meta}
+}
+
+type metaReadCloser struct {
+	io.ReadCloser
+	meta *Metadata
+}
+
+func (p *metaReadCloser) Metadata() *Metadata {
+	return p.meta
+}
+
+// Unwrap returns the underlying stream.
+func (p *metaReadCloser) Unwrap() io.ReadCloser {
+	return p.ReadCloser
+}
+
+// -------------------------------------------------------------------------------------
+
+// ProgressFunc reports reading progress: n bytes of total have been read.
+// total is -1 if unknown.
+type ProgressFunc = func(n, total int64)
+
+// WithProgress returns a stream that reads from rc and calls fn after each
+// read. The total size comes from the metadata of rc, which is preserved.
+func WithProgress(rc io.ReadCloser, fn ProgressFunc) io.ReadCloser {
+	meta := MetadataOf(rc)
+	return &progressReader{metaReadCloser{rc, meta}, fn, 0}
+}
+
+type progressReader struct {
+	metaReadCloser
+	fn ProgressFunc
+	n  int64
+}
+
+func (p *progressReader) Read(b []byte) (n int, err error) {
+	n, err = p.ReadCloser.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.fn(p.n, p.meta.Size)
+	}
+	return
+}
+
+// -------------------------------------------------------------------------------------
@@ -18,6 +18,7 @@ package stream
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"io/fs"
@@ -40,25 +41,60 @@ var (
 // OpenFunc defines the function type for opening a resource by URL.
 type OpenFunc = func(url string) (io.ReadCloser, error)
 
+// OpenContextFunc defines the function type for opening a resource by URL
+// with a context that bounds the open operation (and reading, if the
+// implementation supports it).
+type OpenContextFunc = func(ctx context.Context, url string) (io.ReadCloser, error)
+
 var (
-	openers = map[string]OpenFunc{}
+	openers = map[string]OpenContextFunc{}
 )
 
 // Register registers a scheme with an open function.
+// The open function can't be canceled: OpenContext only checks the context
+// before calling it. Use RegisterContext if possible.
 func Register(scheme string, open OpenFunc) {
+	openers[scheme] = func(ctx context.Context, url string) (io.ReadCloser, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return open(url)
+	}
+}
+
+// RegisterContext registers a scheme with a context-aware open function.
+func RegisterContext(scheme string, open OpenContextFunc) {
 	openers[scheme] = open
 }
 
 // Open opens a resource identified by the given URI.
 // It supports different schemes by utilizing registered open functions.
 // If the URI has no scheme, it is treated as a file path.
+// If SetAutoDecode(true) is called, it decodes the resource like OpenDecoded.
 func Open(uri string) (io.ReadCloser, error) {
+	return OpenContext(context.Background(), uri)
+}
+
+// OpenContext opens a resource identified by the given URI with ctx.
+// See Open.
+func OpenContext(ctx context.Context, uri string) (io.ReadCloser, error) {
+	if autoDecode.Load() {
+		return OpenDecoded(ctx, uri)
+	}
+	return OpenRaw(ctx, uri)
+}
+
+// OpenRaw opens a resource like OpenContext but never decodes it.
+func OpenRaw(ctx context.Context, uri string) (io.ReadCloser, error) {
 	scheme := schemeOf(uri)
 	if scheme == "" {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		return os.Open(uri)
 	}
 	if open, ok := openers[scheme]; ok {
-		return open(uri)
+		return open(ctx, uri)
 	}
 	return nil, &fs.PathError{Op: "stream.Open", Err: ErrUnknownScheme, Path: uri}
 }
@@ -112,12 +148,27 @@ func ReadSourceLocal(filename string, src any) ([]byte, error) {
 // If src != nil, it reads from src; otherwise, it opens the URI and reads
 // from it.
 func ReadSourceFromURI(uri string, src any) ([]byte, error) {
+	return ReadSourceFromURIContext(context.Background(), uri, src)
+}
+
+// maxSizeHint limits preallocation by the size of a stream.
+const maxSizeHint = 1 << 20
+
+// ReadSourceFromURIContext is like ReadSourceFromURI but opens the URI with
+// ctx.
+func ReadSourceFromURIContext(ctx context.Context, uri string, src any) ([]byte, error) {
 	if src == nil {
-		f, err := Open(uri)
+		f, err := OpenContext(ctx, uri)
 		if err != nil {
 			return nil, err
 		}
 		defer f.Close()
+		if size := MetadataOf(f).Size; size >= 0 {
+			// size is only a hint, which may come from a server
+			buf := bytes.NewBuffer(make([]byte, 0, min(size, maxSizeHint)+bytes.MinRead))
+			_, err = buf.ReadFrom(f)
+			return buf.Bytes(), err
+		}
 		src = f
 	}
 	return ReadSource(src)
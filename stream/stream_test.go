@@ -17,13 +17,28 @@
 package stream_test
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/qiniu/x/mockhttp"
 	"github.com/qiniu/x/stream"
+	streamhttp "github.com/qiniu/x/stream/http"
+	_ "github.com/qiniu/x/stream/http/nocache"
 	_ "github.com/qiniu/x/stream/inline"
+	_ "github.com/qiniu/x/stream/mem"
+	_ "github.com/qiniu/x/stream/zip"
 )
 
 func TestBasic(t *testing.T) {
@@ -87,4 +102,343 @@ func TestReadSourceFromURI(t *testing.T) {
 	if _, err := stream.ReadSourceFromURI("unknown:abc", nil); err == nil {
 		t.Fatal("ReadSourceFromURI failed: no error?")
 	}
+	stream.Register("liar", func(url string) (io.ReadCloser, error) {
+		rc := io.NopCloser(strings.NewReader("abc"))
+		return stream.WithMetadata(rc, &stream.Metadata{Size: 9223372036854775000}), nil
+	})
+	if b, err := stream.ReadSourceFromURI("liar:abc", nil); err != nil || string(b) != "abc" {
+		t.Fatal("ReadSourceFromURI with a bad size:", string(b), err)
+	}
+}
+
+func TestOpenContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := stream.OpenContext(ctx, "inline:hello"); err != context.Canceled {
+		t.Fatal("OpenContext inline:", err)
+	}
+	if _, err := stream.OpenContext(ctx, "/bin/not-exists/foo"); err != context.Canceled {
+		t.Fatal("OpenContext file:", err)
+	}
+	stream.Register("legacy", func(url string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(url)), nil
+	})
+	if _, err := stream.OpenContext(ctx, "legacy:foo"); err != context.Canceled {
+		t.Fatal("OpenContext legacy:", err)
+	}
+	if _, err := stream.ReadSourceFromURIContext(ctx, "legacy:foo", nil); err != context.Canceled {
+		t.Fatal("ReadSourceFromURIContext:", err)
+	}
+	b, err := stream.ReadSourceFromURIContext(context.Background(), "legacy:foo", nil)
+	if err != nil || string(b) != "legacy:foo" {
+		t.Fatal("ReadSourceFromURIContext legacy:", string(b), err)
+	}
+}
+
+func TestStat(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	txt := filepath.Join(dir, "a.txt")
+	os.WriteFile(txt, []byte("hello"), 0644)
+	if meta, err := stream.Stat(ctx, txt); err != nil || meta.Size != 5 || !strings.HasPrefix(meta.ContentType, "text/plain") {
+		t.Fatal("Stat file:", meta, err)
+	}
+	if meta, err := stream.Stat(ctx, "inline:hello world"); err != nil || meta.Size != 11 {
+		t.Fatal("Stat inline:", meta, err)
+	}
+	if _, err := stream.Stat(ctx, "bad:foo"); err == nil {
+		t.Fatal("Stat bad scheme: no error")
+	}
+
+	zipfile := filepath.Join(dir, "a.zip")
+	f, _ := os.Create(zipfile)
+	zw := zip.NewWriter(f)
+	modt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	w, _ := zw.CreateHeader(&zip.FileHeader{Name: "b.json", Modified: modt})
+	io.WriteString(w, `{"a":1}`)
+	zw.Close()
+	f.Close()
+	meta, err := stream.Stat(ctx, "zip:"+zipfile+"#b.json")
+	if err != nil || meta.Size != 7 || !meta.ModTime.Equal(modt) || meta.ContentType != "application/json" {
+		t.Fatal("Stat zip:", meta, err)
+	}
+}
+
+func TestHttpMetadata(t *testing.T) {
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("meta.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Length", "5")
+		h.Set("Content-Type", "text/plain")
+		h.Set("ETag", `"abc"`)
+		h.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Method != "HEAD" {
+			io.WriteString(w, "hello")
+		}
+	}))
+	old := streamhttp.Client
+	streamhttp.Client = &http.Client{Transport: tr}
+	defer func() { streamhttp.Client = old }()
+
+	meta, err := stream.Stat(context.Background(), "http://meta.com/a")
+	if err != nil || meta.Size != 5 || meta.ETag != `"abc"` || meta.ModTime.Year() != 2006 {
+		t.Fatal("Stat http:", meta, err)
+	}
+	if calls := tr.Calls(); len(calls) != 1 || calls[0].Method != "HEAD" {
+		t.Fatal("Stat http: not a HEAD request")
+	}
+
+	f, err := stream.Open("http://meta.com/a")
+	if err != nil {
+		t.Fatal("Open http:", err)
+	}
+	var progress []int64
+	rc := stream.WithProgress(f, func(n, total int64) {
+		progress = append(progress, n, total)
+	})
+	defer rc.Close()
+	if meta := stream.MetadataOf(rc); meta.ContentType != "text/plain" {
+		t.Fatal("MetadataOf:", meta)
+	}
+	if b, err := io.ReadAll(rc); err != nil || string(b) != "hello" {
+		t.Fatal("ReadAll:", string(b), err)
+	}
+	if len(progress) != 2 || progress[0] != 5 || progress[1] != 5 {
+		t.Fatal("progress:", progress)
+	}
+}
+
+func TestDataURL(t *testing.T) {
+	cases := []struct {
+		url, data, ctype string
+		params           map[string]string
+	}{
+		{"data:,A%20brief%20note", "A brief note", "text/plain; charset=US-ASCII", map[string]string{"charset": "US-ASCII"}},
+		{"data:text/plain;charset=utf-8;base64,aGVsbG8=", "hello", "text/plain; charset=utf-8", map[string]string{"charset": "utf-8"}},
+		{"data:application/octet-stream;base64,AAEC/w", "\x00\x01\x02\xff", "application/octet-stream", map[string]string{}},
+		{"data:;charset=utf-8,%E4%BD%A0", "你", "text/plain; charset=utf-8", map[string]string{"charset": "utf-8"}},
+	}
+	for _, c := range cases {
+		f, err := stream.Open(c.url)
+		if err != nil {
+			t.Fatal("Open:", c.url, err)
+		}
+		b, _ := io.ReadAll(f)
+		meta := stream.MetadataOf(f)
+		f.Close()
+		if string(b) != c.data || meta.Size != int64(len(c.data)) || meta.ContentType != c.ctype || !reflect.DeepEqual(meta.Params, c.params) {
+			t.Fatal("data URL:", c.url, string(b), meta)
+		}
+	}
+	for _, url := range []string{"data:text/plain", "data:;base64,!!!"} {
+		if _, err := stream.Open(url); err == nil {
+			t.Fatal("Open:", url, "no error")
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, `{"a":1}`)
+	zw.Close()
+	gz := filepath.Join(dir, "a.json.gz")
+	os.WriteFile(gz, buf.Bytes(), 0644)
+	log := filepath.Join(dir, "a.log")
+	os.WriteFile(log, buf.Bytes(), 0644)
+
+	stream.RegisterCodec(&stream.Codec{Name: "upper", NewReader: func(r io.Reader) (io.ReadCloser, error) {
+		b, err := io.ReadAll(r)
+		return io.NopCloser(strings.NewReader(strings.ToUpper(string(b)))), err
+	}})
+	ctx := context.Background()
+	cases := []struct {
+		uri, ret string
+	}{
+		{gz, `{"a":1}`},
+		{gz + "?decode=none", buf.String()},
+		{log, buf.String()},
+		{log + "?decode=gzip", `{"a":1}`},
+		{log + "?decode=gzip,upper", `{"A":1}`},
+	}
+	for _, c := range cases {
+		f, err := stream.OpenDecoded(ctx, c.uri)
+		if err != nil {
+			t.Fatal("OpenDecoded:", c.uri, err)
+		}
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(b) != c.ret {
+			t.Fatal("OpenDecoded:", c.uri, string(b), err)
+		}
+	}
+	f, _ := stream.OpenDecoded(ctx, gz)
+	if meta := stream.MetadataOf(f); meta.Size != -1 || meta.ContentType != "application/json" {
+		t.Fatal("MetadataOf:", meta)
+	}
+	f.Close()
+	if _, err := stream.OpenDecoded(ctx, log+"?decode=bad"); !errors.Is(err, stream.ErrUnknownCodec) {
+		t.Fatal("OpenDecoded bad codec:", err)
+	}
+}
+
+func TestAutoDecode(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, "hello")
+	zw.Close()
+	gz := filepath.Join(t.TempDir(), "a.txt.gz")
+	os.WriteFile(gz, buf.Bytes(), 0644)
+
+	if b, err := stream.ReadSourceFromURI(gz, nil); err != nil || !bytes.Equal(b, buf.Bytes()) {
+		t.Fatal("ReadSourceFromURI raw:", b, err)
+	}
+	stream.SetAutoDecode(true)
+	defer stream.SetAutoDecode(false)
+	f, err := stream.Open(gz)
+	if err != nil {
+		t.Fatal("Open:", err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "hello" {
+		t.Fatal("Open decoded:", string(b), err)
+	}
+	if b, err := stream.ReadSourceFromURI(gz+"?decode=none", nil); err != nil || !bytes.Equal(b, buf.Bytes()) {
+		t.Fatal("ReadSourceFromURI decode=none:", b, err)
+	}
+	if b, err := stream.ReadSourceFromURI("file://"+filepath.ToSlash(gz), nil); err != nil || string(b) != "hello" {
+		t.Fatal("ReadSourceFromURI file:", string(b), err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	os.WriteFile(file, []byte("old"), 0600)
+	for _, uri := range []string{file, "file://" + file} {
+		w, err := stream.Create(ctx, uri)
+		if err != nil {
+			t.Fatal("Create:", uri, err)
+		}
+		if _, ok := w.(io.ReaderFrom); ok {
+			t.Fatal("Create: writer exposes ReadFrom")
+		}
+		io.WriteString(w, "new")
+		if b, _ := os.ReadFile(file); string(b) == "new" {
+			t.Fatal("Create: written before Close")
+		}
+		if err = w.Close(); err != nil {
+			t.Fatal("Close:", err)
+		}
+		b, err := stream.ReadSourceFromURI(uri, nil)
+		if err != nil || string(b) != "new" {
+			t.Fatal("read after Create:", string(b), err)
+		}
+		os.WriteFile(file, []byte("old"), 0600)
+	}
+	cctx, cancel := context.WithCancel(ctx)
+	w, _ := stream.Create(cctx, file)
+	io.WriteString(w, "canceled")
+	cancel()
+	if err := w.Close(); err != context.Canceled {
+		t.Fatal("Close canceled:", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if b, _ := os.ReadFile(file); string(b) != "old" || len(entries) != 1 {
+		t.Fatal("canceled Create:", string(b), len(entries))
+	}
+	if fi, _ := os.Stat(file); fi.Mode().Perm() != 0600 {
+		t.Fatal("mode not kept:", fi.Mode())
+	}
+
+	w, err := stream.Create(ctx, "mem:a.json")
+	if err != nil {
+		t.Fatal("Create mem:", err)
+	}
+	io.WriteString(w, `{"a":1}`)
+	if _, err = stream.Open("mem:a.json"); err == nil {
+		t.Fatal("mem: visible before Close")
+	}
+	w.Close()
+	if meta, err := stream.Stat(ctx, "mem:a.json"); err != nil || meta.Size != 7 || meta.ContentType != "application/json" {
+		t.Fatal("Stat mem:", meta, err)
+	}
+	if b, err := stream.ReadSourceFromURI("mem:a.json", nil); err != nil || string(b) != `{"a":1}` {
+		t.Fatal("read mem:", string(b), err)
+	}
+
+	if _, err := stream.Create(ctx, "bad:foo"); err == nil {
+		t.Fatal("Create bad scheme: no error")
+	}
+}
+
+func TestCreateHttp(t *testing.T) {
+	var got []string
+	tr := mockhttp.NewTransport()
+	tr.ListenAndServe("upload.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = append(got, r.Method, string(b))
+		if r.URL.Path == "/denied" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	old := streamhttp.Client
+	streamhttp.Client = &http.Client{Transport: tr}
+	defer func() { streamhttp.Client = old }()
+
+	ctx := context.Background()
+	w, err := stream.Create(ctx, "http://upload.com/a")
+	if err != nil {
+		t.Fatal("Create:", err)
+	}
+	io.WriteString(w, "hello ")
+	io.WriteString(w, "world")
+	if err = w.Close(); err != nil {
+		t.Fatal("Close:", err)
+	}
+	if len(got) != 2 || got[0] != "PUT" || got[1] != "hello world" {
+		t.Fatal("PUT:", got)
+	}
+	if calls := tr.Calls(); len(calls) != 1 {
+		t.Fatal("calls:", len(calls))
+	}
+
+	w, _ = streamhttp.CreateMethod(ctx, "POST", "http://upload.com/denied", nil)
+	io.WriteString(w, "x")
+	if err = w.Close(); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatal("POST denied:", err)
+	}
+	if got[2] != "POST" {
+		t.Fatal("POST:", got)
+	}
+}
+
+func TestCreateHttpEarlyResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadFull(r.Body, make([]byte, 5))
+		r.Body.Close() // respond without reading the rest
+	}))
+	defer ts.Close()
+	old := streamhttp.Client
+	streamhttp.Client = ts.Client()
+	defer func() { streamhttp.Client = old }()
+
+	w, err := stream.Create(context.Background(), ts.URL+"/a")
+	if err != nil {
+		t.Fatal("Create:", err)
+	}
+	chunk := make([]byte, 64<<10) // large enough to be flushed to the server
+	deadline := time.Now().Add(5 * time.Second)
+	for err == nil && time.Now().Before(deadline) {
+		_, err = w.Write(chunk)
+	}
+	if err == nil {
+		t.Fatal("Write after early response: no error")
+	}
+	if err = w.Close(); !errors.Is(err, streamhttp.ErrTruncated) {
+		t.Fatal("Close after early response:", err)
+	}
 }
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2026 The XGo Authors (xgo.dev). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tar
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/qiniu/x/stream"
+	"github.com/qiniu/x/stream/archive"
+)
+
+// -------------------------------------------------------------------------------------
+
+func parse(url string) (archive.Format, string) {
+	if file, ok := strings.CutPrefix(url, "tgz:"); ok {
+		return archive.Tgz, file
+	}
+	return archive.Tar, strings.TrimPrefix(url, "tar:")
+}
+
+// Open opens a file object in a tar archive.
+// The url format: tar:<tarfile>#<name> or tgz:<tgzfile>#<name>, where
+// <tarfile> can be any stream URI, and <name> can be followed by names in
+// nested archives (eg. tar:a.tar#inner.zip#b.txt).
+func Open(url string) (io.ReadCloser, error) {
+	return OpenContext(context.Background(), url)
+}
+
+// OpenContext opens a file object in a tar archive with ctx. See Open.
+func OpenContext(ctx context.Context, url string) (io.ReadCloser, error) {
+	format, file := parse(url)
+	return archive.Open(ctx, format, file)
+}
+
+// Stat returns metadata of a file object in a tar archive without reading
+// it.
+func Stat(ctx context.Context, url string) (*stream.Metadata, error) {
+	format, file := parse(url)
+	return archive.Stat(ctx, format, file)
+}
+
+func init() {
+	// tar:file.tar#index.htm
+	// tgz:file.tar.gz#index.htm
+	stream.RegisterContext("tar", OpenContext)
+	stream.RegisterContext("tgz", OpenContext)
+	stream.RegisterStat("tar", Stat)
+	stream.RegisterStat("tgz", Stat)
+}
+
+// -------------------------------------------------------------------------------------
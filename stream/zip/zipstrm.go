@@ -17,58 +17,38 @@
 package zip
 
 import (
-	"archive/zip"
+	"context"
 	"io"
-	"io/fs"
 	"strings"
 
 	"github.com/qiniu/x/stream"
+	"github.com/qiniu/x/stream/archive"
 )
 
 // -------------------------------------------------------------------------------------
 
-type readCloser struct {
-	io.ReadCloser
-	zipf *zip.ReadCloser
+// Open opens a zipped file object.
+// The url format: zip:<zipfile>#<name>, where <zipfile> can be any stream
+// URI (eg. zip:http://example.com/a.zip#b.txt), and <name> can be followed
+// by names in nested archives (eg. zip:a.zip#inner.zip#b.txt).
+func Open(url string) (io.ReadCloser, error) {
+	return OpenContext(context.Background(), url)
 }
 
-func (p *readCloser) Close() error {
-	err := p.ReadCloser.Close()
-	if err2 := p.zipf.Close(); err2 != nil && err == nil {
-		err = err2
-	}
-	return err
+// OpenContext opens a zipped file object with ctx. See Open.
+func OpenContext(ctx context.Context, url string) (io.ReadCloser, error) {
+	return archive.Open(ctx, archive.Zip, strings.TrimPrefix(url, "zip:"))
 }
 
-// Open opens a zipped file object.
-func Open(url string) (io.ReadCloser, error) {
-	file := strings.TrimPrefix(url, "zip:")
-	pos := strings.Index(file, "#")
-	if pos <= 0 {
-		return nil, fs.ErrInvalid
-	}
-	zipfile, name := file[:pos], file[pos+1:]
-	zipf, err := zip.OpenReader(zipfile)
-	if err != nil {
-		return nil, err
-	}
-	for _, fi := range zipf.File {
-		if fi.Name == name {
-			f, err := fi.Open()
-			if err != nil {
-				zipf.Close()
-				return nil, err
-			}
-			return &readCloser{f, zipf}, nil
-		}
-	}
-	zipf.Close()
-	return nil, fs.ErrNotExist
+// Stat returns metadata of a zipped file object without reading it.
+func Stat(ctx context.Context, url string) (*stream.Metadata, error) {
+	return archive.Stat(ctx, archive.Zip, strings.TrimPrefix(url, "zip:"))
 }
 
 func init() {
 	// zip:file#index.htm
-	stream.Register("zip", Open)
+	stream.RegisterContext("zip", OpenContext)
+	stream.RegisterStat("zip", Stat)
 }
 
 // -------------------------------------------------------------------------------------